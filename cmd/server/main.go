@@ -4,9 +4,19 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/invoices"
+	"github.com/teeaa/studio/internal/notify"
+	"github.com/teeaa/studio/internal/payments"
+	"github.com/teeaa/studio/internal/reminders"
+	"github.com/teeaa/studio/internal/waitlist"
+	"github.com/teeaa/studio/internal/webhooks"
 )
 
 var db *gorm.DB
@@ -14,13 +24,228 @@ var db *gorm.DB
 func main() {
 	log.SetLevel(log.DebugLevel)
 	fmt.Println("Dance studio Go server")
+	setStudioTimezone()
+	setCancellationHours()
+	setInvoicing()
+	setPaymentProvider()
+	setPaymentWindow()
+	setNotifications()
+	setReminderWindow()
 
 	srv := startServer()
 	defer srv.Close()
 
+	go expirePayments()
+	go refundPayments()
+	go retryWebhooks()
+	if reminders.Enabled() {
+		go remindSessions()
+	}
+	if notify.Enabled() {
+		go notifyPromotions()
+	}
+
 	waitForExit()
 }
 
+// Set timezone classes are held in unless they define their own
+func setStudioTimezone() {
+	timezone := os.Getenv("DANCESTUDIO_TIMEZONE")
+	if len(timezone) == 0 {
+		return
+	}
+
+	err := classes.SetStudioTimezone(timezone)
+	if err != nil {
+		log.Error("Invalid DANCESTUDIO_TIMEZONE: ", err)
+		os.Exit(1)
+	}
+}
+
+// Set hours before the session bookings can be cancelled free of charge unless classes define their own
+func setCancellationHours() {
+	value := os.Getenv("DANCESTUDIO_CANCELLATION_HOURS")
+	if len(value) == 0 {
+		return
+	}
+
+	hours, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		log.Error("Invalid DANCESTUDIO_CANCELLATION_HOURS: ", err)
+		os.Exit(1)
+	}
+
+	bookings.SetCancellationHours(uint(hours))
+}
+
+// Set VAT rate applied to prices of classes which don't define their own, and the invoice currency
+func setInvoicing() {
+	value := os.Getenv("DANCESTUDIO_VAT_RATE")
+	if len(value) > 0 {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			err = invoices.SetVATRate(rate)
+		}
+		if err != nil {
+			log.Error("Invalid DANCESTUDIO_VAT_RATE: ", err)
+			os.Exit(1)
+		}
+	}
+
+	currency := os.Getenv("DANCESTUDIO_CURRENCY")
+	if len(currency) > 0 {
+		err := invoices.SetCurrency(currency)
+		if err != nil {
+			log.Error("Invalid DANCESTUDIO_CURRENCY: ", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Set payment provider sessions sold on their own are paid through from DANCESTUDIO_PAYMENT_PROVIDER.
+// Only the fake provider is built in, and without a provider such sessions can't be booked.
+func setPaymentProvider() {
+	name := os.Getenv("DANCESTUDIO_PAYMENT_PROVIDER")
+	switch name {
+	case "":
+		log.Warn("No DANCESTUDIO_PAYMENT_PROVIDER set, sessions sold on their own can't be booked")
+	case "fake":
+		log.Warn("Using the fake payment provider, payments are confirmed without being paid. Never use it in production.")
+		payments.SetProvider(payments.NewFakeProvider())
+	default:
+		log.Error("Invalid DANCESTUDIO_PAYMENT_PROVIDER: ", name)
+		os.Exit(1)
+	}
+}
+
+// Set minutes a booking is held pending for its payment before it's released
+func setPaymentWindow() {
+	value := os.Getenv("DANCESTUDIO_PAYMENT_MINUTES")
+	if len(value) == 0 {
+		return
+	}
+
+	minutes, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || minutes == 0 {
+		log.Error("Invalid DANCESTUDIO_PAYMENT_MINUTES: ", value)
+		os.Exit(1)
+	}
+
+	payments.SetPaymentWindow(time.Duration(minutes) * time.Minute)
+}
+
+// Send email notifications to members through the SMTP server at DANCESTUDIO_SMTP_ADDRESS, rendered
+// from the templates in DANCESTUDIO_TEMPLATES, and remind them of their sessions by email.
+// Notifications are off when no server is given.
+func setNotifications() {
+	address := os.Getenv("DANCESTUDIO_SMTP_ADDRESS")
+	if len(address) == 0 {
+		log.Info("DANCESTUDIO_SMTP_ADDRESS not set, email notifications are off")
+		return
+	}
+
+	dir := os.Getenv("DANCESTUDIO_TEMPLATES")
+	if len(dir) == 0 {
+		dir = "templates/email"
+	}
+	templates, err := notify.LoadTemplates(dir)
+	if err != nil {
+		log.Error("Invalid DANCESTUDIO_TEMPLATES: ", err)
+		os.Exit(1)
+	}
+
+	from := os.Getenv("DANCESTUDIO_SMTP_FROM")
+	if len(from) == 0 {
+		from = "noreply@localhost"
+	}
+
+	notify.Setup(&notify.SMTPMailer{
+		Address:  address,
+		From:     from,
+		Username: os.Getenv("DANCESTUDIO_SMTP_USER"),
+		Password: os.Getenv("DANCESTUDIO_SMTP_PASSWORD"),
+	}, templates)
+	reminders.SetChannel(reminders.EmailChannel{})
+}
+
+// Give up payments which never arrived every minute, releasing their bookings
+func expirePayments() {
+	for now := range time.Tick(time.Minute) {
+		expired, err := payments.ExpireOverdue(now.UTC())
+		if err != nil {
+			log.Error("Error expiring overdue payments: ", err)
+		} else if expired > 0 {
+			log.Infof("Expired %d overdue payments", expired)
+		}
+	}
+}
+
+// Make the refunds recorded for cancelled bookings and late payments at the provider every minute
+func refundPayments() {
+	for range time.Tick(time.Minute) {
+		refunded, err := payments.RefundDue()
+		if err != nil {
+			log.Error("Error refunding payments: ", err)
+		} else if refunded > 0 {
+			log.Infof("Refunded %d payments", refunded)
+		}
+	}
+}
+
+// Retry webhook deliveries which have failed every minute, once their backoff has passed
+func retryWebhooks() {
+	for now := range time.Tick(time.Minute) {
+		delivered, err := webhooks.DeliverDue(now.UTC())
+		if err != nil {
+			log.Error("Error retrying webhook deliveries: ", err)
+		} else if delivered > 0 {
+			log.Infof("Delivered %d webhooks on retry", delivered)
+		}
+	}
+}
+
+// Set hours before the session members are reminded of their bookings
+func setReminderWindow() {
+	value := os.Getenv("DANCESTUDIO_REMINDER_HOURS")
+	if len(value) == 0 {
+		return
+	}
+
+	hours, err := strconv.ParseUint(value, 10, 32)
+	if err == nil {
+		err = reminders.SetWindow(time.Duration(hours) * time.Hour)
+	}
+	if err != nil {
+		log.Error("Invalid DANCESTUDIO_REMINDER_HOURS: ", value)
+		os.Exit(1)
+	}
+}
+
+// Remind members of their upcoming sessions every minute. Reminders go out by email,
+// so they're only scheduled when email notifications are on.
+func remindSessions() {
+	for now := range time.Tick(time.Minute) {
+		sent, err := reminders.SendDue(now.UTC())
+		if err != nil {
+			log.Error("Error sending session reminders: ", err)
+		} else if sent > 0 {
+			log.Infof("Sent %d session reminders", sent)
+		}
+	}
+}
+
+// Notify members booked from the waitlist every minute, once their promotions have been committed
+func notifyPromotions() {
+	for range time.Tick(time.Minute) {
+		notified, err := waitlist.NotifyPromoted()
+		if err != nil {
+			log.Error("Error notifying waitlist promotions: ", err)
+		} else if notified > 0 {
+			log.Infof("Notified %d waitlist promotions", notified)
+		}
+	}
+}
+
 func waitForExit() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -2,12 +2,27 @@ package main
 
 import (
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/blackouts"
 	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/calendar"
 	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/instructors"
+	"github.com/teeaa/studio/internal/invoices"
+	"github.com/teeaa/studio/internal/members"
+	"github.com/teeaa/studio/internal/memberships"
+	"github.com/teeaa/studio/internal/migrations"
+	"github.com/teeaa/studio/internal/passes"
+	"github.com/teeaa/studio/internal/payments"
+	"github.com/teeaa/studio/internal/promotions"
+	"github.com/teeaa/studio/internal/reminders"
+	"github.com/teeaa/studio/internal/rooms"
+	"github.com/teeaa/studio/internal/waitlist"
+	"github.com/teeaa/studio/internal/webhooks"
 )
 
 func startServer() *http.Server {
@@ -34,8 +49,37 @@ func getRouter() *mux.Router {
 	router.Use(logRequest, setHeaders)
 
 	gormDB := Connect()
-	classes.Routes(gormDB, router.PathPrefix("/classes").Subrouter())
+	classesRouter := router.PathPrefix("/classes").Subrouter()
+	classes.Routes(gormDB, classesRouter)
 	bookings.Routes(gormDB, router.PathPrefix("/bookings").Subrouter())
+	bookings.ClassRoutes(gormDB, classesRouter)
+	waitlist.Routes(gormDB, classesRouter.PathPrefix("/{id}/waitlist").Subrouter())
+	blackouts.Routes(gormDB, router.PathPrefix("/blackouts").Subrouter())
+	membersRouter := router.PathPrefix("/members").Subrouter()
+	members.Routes(gormDB, membersRouter)
+	bookings.MemberRoutes(gormDB, membersRouter)
+	passes.Routes(gormDB, router.PathPrefix("/pass-types").Subrouter())
+	passes.MemberRoutes(gormDB, membersRouter)
+	memberships.Routes(gormDB, router.PathPrefix("/memberships").Subrouter())
+	memberships.MemberRoutes(gormDB, membersRouter)
+	instructorsRouter := router.PathPrefix("/instructors").Subrouter()
+	instructors.Routes(gormDB, instructorsRouter)
+	classes.InstructorRoutes(gormDB, instructorsRouter)
+	rooms.Routes(gormDB, router.PathPrefix("/rooms").Subrouter())
+	invoices.Routes(gormDB, router.PathPrefix("/invoices").Subrouter())
+	invoices.MemberRoutes(gormDB, membersRouter)
+	payments.Routes(gormDB, router.PathPrefix("/payments").Subrouter())
+	promotions.Routes(gormDB, router.PathPrefix("/discount-codes").Subrouter())
+	reminders.Setup(gormDB)
+	webhooks.Routes(gormDB, router.PathPrefix("/webhooks").Subrouter())
+	calendar.Routes(gormDB, router.PathPrefix("/calendar").Subrouter())
+	calendar.MemberRoutes(gormDB, membersRouter)
+
+	err := migrations.Run(gormDB)
+	if err != nil {
+		log.Error("Unable to migrate DB: ", err)
+		os.Exit(1)
+	}
 
 	return router
 }
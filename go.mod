@@ -3,6 +3,7 @@ module github.com/teeaa/studio
 go 1.12
 
 require (
+	github.com/go-sql-driver/mysql v1.4.1
 	github.com/gorilla/mux v1.7.3
 	github.com/jinzhu/gorm v1.9.10
 	github.com/selvatico/go-mocket v1.0.7
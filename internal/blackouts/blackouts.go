@@ -0,0 +1,103 @@
+package blackouts
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getBlackouts(w http.ResponseWriter, r *http.Request) {
+	var blackouts []Blackout
+	err := db.Order("start_date ASC").Find(&blackouts).Error
+
+	if err != nil {
+		log.Error("Error fetching blackouts from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&blackouts)
+}
+
+func addBlackout(w http.ResponseWriter, r *http.Request) {
+	var blackout Blackout
+	err := json.NewDecoder(r.Body).Decode(&blackout)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new blackout: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for blackout")
+		return
+	}
+
+	err = db.Create(&blackout).Error
+	if err != nil {
+		log.Error("Error inserting blackout to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&blackout)
+}
+
+func getBlackout(w http.ResponseWriter, r *http.Request) {
+	blackout, err := db.getBlackoutFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&blackout)
+}
+
+func updateBlackout(w http.ResponseWriter, r *http.Request) {
+	blackout, err := db.getBlackoutFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&blackout)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating blackout: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for blackout")
+		return
+	}
+
+	err = db.Save(&blackout).Error
+	if err != nil {
+		log.Error("Error saving blackout to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&blackout)
+}
+
+func deleteBlackout(w http.ResponseWriter, r *http.Request) {
+	blackout, err := db.getBlackoutFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = db.Delete(&blackout).Error
+	if err != nil {
+		log.Error("Error deleting blackout from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Blackout removed")
+}
+
+// Routes set routes for /blackouts
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Blackout{})
+
+	router.HandleFunc("", getBlackouts).Methods("GET")
+	router.HandleFunc("", addBlackout).Methods("POST")
+	router.HandleFunc("/{id}", getBlackout).Methods("GET")
+	router.HandleFunc("/{id}", updateBlackout).Methods("PUT")
+	router.HandleFunc("/{id}", deleteBlackout).Methods("DELETE")
+}
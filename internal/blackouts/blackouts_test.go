@@ -0,0 +1,124 @@
+package blackouts
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+func TestAddBlackout(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset().NewMock().WithQuery(`INSERT  INTO "blackouts"`).WithID(2)
+
+	w, r := makeRequest(map[string]interface{}{"name": "Christmas", "start_date": "2019-12-23", "end_date": "2020-01-06"}, nil)
+	addBlackout(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var blackout Blackout
+	err = json.Unmarshal(body, &blackout)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if blackout.Name != "Christmas" || !blackout.StartDate.Equal(time.Date(2019, 12, 23, 0, 0, 0, 0, time.UTC)) ||
+		!blackout.EndDate.Equal(time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)) || blackout.RoomID != nil {
+		t.Error("Received blackout didn't match expectations:", blackout)
+	}
+}
+
+func TestAddBlackoutEndBeforeStart(t *testing.T) {
+	w, r := makeRequest(map[string]interface{}{"name": "Backwards", "start_date": "2019-12-23", "end_date": "2019-12-01"}, nil)
+	addBlackout(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestGetBlackouts(t *testing.T) {
+	commonReply := []map[string]interface{}{{
+		"id":         2,
+		"name":       "Christmas",
+		"start_date": time.Date(2019, 12, 23, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+		"room_id":    3,
+	}}
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "blackouts"`).WithReply(commonReply)
+
+	w, r := makeRequest(nil, nil)
+	getBlackouts(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var blackouts []map[string]interface{}
+	err = json.Unmarshal(body, &blackouts)
+	if err != nil {
+		t.Error("Failed to serialise response to json:", err)
+	}
+	if len(blackouts) != 1 || blackouts[0]["id"] != float64(2) || blackouts[0]["room_id"] != float64(3) || blackouts[0]["end_date"] != "2020-01-06" {
+		t.Error("Received blackouts didn't match expectations:", blackouts)
+	}
+}
+
+func TestDeleteBlackout(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "blackouts"  WHERE ("blackouts"."id" = 2)`).WithReply([]map[string]interface{}{{"id": 2, "name": "Christmas"}})
+
+	deleteBlackout(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+}
+
+func TestDeleteBlackoutNonExisting(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "345"})
+
+	deleteBlackout(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/blackouts", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
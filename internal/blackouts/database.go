@@ -0,0 +1,52 @@
+package blackouts
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// GetOverlapping get blackouts closing any date between from and to
+func GetOverlapping(tx *gorm.DB, from time.Time, to time.Time) ([]Blackout, error) {
+	var blackouts []Blackout
+	err := tx.Where("start_date <= ? AND end_date >= ?", to, from).Order("start_date ASC").Find(&blackouts).Error
+
+	return blackouts, err
+}
+
+// Get blackout from database by id in request and handle error situations
+func (db *Database) getBlackoutFromReq(w http.ResponseWriter, r *http.Request) (*Blackout, error) {
+	var blackout Blackout
+	vars := mux.Vars(r)
+	blackoutID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested blackout id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid blackout ID")
+		return nil, err
+	}
+
+	err = db.First(&blackout, blackoutID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested blackout by id %d does not exist", blackoutID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Blackout does not exist")
+		} else {
+			log.Error("Error fetching blackout from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &blackout, nil
+}
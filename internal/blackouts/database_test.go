@@ -0,0 +1,41 @@
+package blackouts
+
+import (
+	"testing"
+	"time"
+
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func TestGetOverlapping(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "blackouts"  WHERE (start_date <= 2019-12-31 00:00:00 +0000 UTC AND end_date >= 2019-12-24 00:00:00 +0000 UTC)`).WithReply([]map[string]interface{}{{
+		"id":         2,
+		"name":       "Christmas",
+		"start_date": time.Date(2019, 12, 23, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+	}})
+
+	blackouts, err := GetOverlapping(db.DB, time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error getting overlapping blackouts:", err)
+	}
+
+	if len(blackouts) != 1 || blackouts[0].Name != "Christmas" {
+		t.Error("Retrieved blackouts didn't match expectations:", blackouts)
+	}
+}
+
+func TestBlackoutCovers(t *testing.T) {
+	room := uint64(3)
+	otherRoom := uint64(4)
+	studio := Blackout{StartDate: time.Date(2019, 12, 23, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2019, 12, 26, 0, 0, 0, 0, time.UTC)}
+	roomOnly := Blackout{StartDate: time.Date(2019, 12, 23, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2019, 12, 26, 0, 0, 0, 0, time.UTC), RoomID: &room}
+
+	if !studio.Covers(time.Date(2019, 12, 26, 0, 0, 0, 0, time.UTC), &room) || studio.Covers(time.Date(2019, 12, 27, 0, 0, 0, 0, time.UTC), nil) {
+		t.Error("Expected studio blackout to cover its dates inclusively in every room")
+	}
+	if !roomOnly.Covers(time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC), &room) || roomOnly.Covers(time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC), &otherRoom) {
+		t.Error("Expected room blackout to cover only its own room")
+	}
+}
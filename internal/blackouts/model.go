@@ -0,0 +1,99 @@
+package blackouts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Blackout representation of blackouts.blackouts, dates the studio or one of its rooms is closed
+type Blackout struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	Name      string    `json:"name"`
+	StartDate time.Time `gorm:"type:date" json:"start_date"`
+	EndDate   time.Time `gorm:"type:date" json:"end_date"`
+	// Room the blackout is limited to, or nil when the whole studio is closed
+	RoomID *uint64 `json:"room_id"`
+}
+
+// Covers tells if the blackout closes date for classes held in room roomID
+func (b *Blackout) Covers(date time.Time, roomID *uint64) bool {
+	if date.Before(b.StartDate) || date.After(b.EndDate) {
+		return false
+	}
+
+	return b.RoomID == nil || (roomID != nil && *b.RoomID == *roomID)
+}
+
+// Describe blackout for telling why a date can't be booked
+func (b *Blackout) Describe() string {
+	if b.RoomID != nil {
+		return fmt.Sprintf("Room is closed for %s (blackout %d)", b.Name, b.ID)
+	}
+	return fmt.Sprintf("Studio is closed for %s (blackout %d)", b.Name, b.ID)
+}
+
+// MarshalJSON to date correctly
+func (b *Blackout) MarshalJSON() ([]byte, error) {
+	type Alias Blackout
+	return json.Marshal(&struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		*Alias
+	}{
+		StartDate: b.StartDate.Format("2006-01-02"),
+		EndDate:   b.EndDate.Format("2006-01-02"),
+		Alias:     (*Alias)(b),
+	})
+}
+
+// UnmarshalJSON to date correctly and strip ID field from requests
+func (b *Blackout) UnmarshalJSON(data []byte) error {
+	type Alias Blackout
+	aux := &struct {
+		ID        uint64 `gorm:"-" sql:"-" json:"id"`
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		*Alias
+	}{
+		Alias: (*Alias)(b),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if b.Name == "" {
+		return errors.New("Missing name in payload")
+	}
+
+	if len(aux.StartDate) < 10 {
+		return errors.New("Invalid start_date in payload")
+	}
+
+	b.StartDate, err = time.Parse("2006-01-02", aux.StartDate[0:10])
+	if err != nil {
+		return err
+	}
+
+	// Single day blackouts can leave out end_date
+	b.EndDate = b.StartDate
+	if aux.EndDate != "" {
+		if len(aux.EndDate) < 10 {
+			return errors.New("Invalid end_date in payload")
+		}
+
+		b.EndDate, err = time.Parse("2006-01-02", aux.EndDate[0:10])
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.EndDate.Before(b.StartDate) {
+		return errors.New("Invalid end_date in payload, must not be before start_date")
+	}
+
+	return nil
+}
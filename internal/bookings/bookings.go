@@ -4,13 +4,17 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	log "github.com/sirupsen/logrus"
 	"github.com/teeaa/studio/internal/classes"
 	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+	"github.com/teeaa/studio/internal/notify"
+	"github.com/teeaa/studio/internal/payments"
+	"github.com/teeaa/studio/internal/promotions"
+	"github.com/teeaa/studio/internal/webhooks"
 )
 
 func getBookings(w http.ResponseWriter, r *http.Request) {
@@ -23,24 +27,75 @@ func getBookings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = db.flagCancelled(bookings)
+	if err != nil {
+		log.Error("Error fetching class exceptions from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
 	json.NewEncoder(w).Encode(&bookings)
 }
 
-func checkValidity(booking Booking, class classes.Class) error {
-	if class == (classes.Class{}) {
+func checkValidity(booking Booking, class classes.Class, calendar classes.Calendar) error {
+	if class.ID == 0 {
 		return errors.New("No such class")
 	}
 
-	class.EndDate = class.EndDate.Add(24 * time.Hour)
-	booking.BookingDate = booking.BookingDate.Add(12 * time.Hour)
+	return class.CheckDate(booking.BookingDate, calendar)
+}
+
+// Get changes to the schedule of the booked class on the booking date
+func getCalendar(booking Booking) (classes.Calendar, error) {
+	return classes.GetCalendar(db.DB, booking.ClassID, booking.BookingDate, booking.BookingDate)
+}
 
-	if !booking.BookingDate.After(class.StartDate) || !booking.BookingDate.Before(class.EndDate) {
-		return errors.New("Booked date outside class start and end")
+// Check the booking member exists
+func checkMember(booking Booking) error {
+	_, err := members.GetMemberByID(booking.MemberID)
+	if err != nil {
+		return errors.New("No such member")
 	}
 
 	return nil
 }
 
+// Validate check booking can be made to its class, capacity aside
+func Validate(booking Booking) error {
+	err := checkMember(booking)
+	if err != nil {
+		return err
+	}
+
+	class, err := classes.GetClassByID(booking.ClassID)
+	if err != nil {
+		return errors.New("No such class")
+	}
+
+	calendar, err := getCalendar(booking)
+	if err != nil {
+		return err
+	}
+
+	return checkValidity(booking, class, calendar)
+}
+
+// Respond to a booking clashing with an existing booking of the same member, pointing to the existing one
+func respondAlreadyBooked(w http.ResponseWriter, booking *Booking) {
+	existing, err := FindBooking(db.DB, booking.MemberID, booking.ClassID, booking.BookingDate, booking.ID)
+	if err != nil {
+		log.Error("Error fetching clashing booking from db: ", err)
+		helpers.ResponseJSON(w, http.StatusConflict, ErrAlreadyBooked.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    ErrAlreadyBooked.Error(),
+		"booking_id": existing.ID,
+	})
+}
+
 func addBooking(w http.ResponseWriter, r *http.Request) {
 	var booking Booking
 	err := json.NewDecoder(r.Body).Decode(&booking)
@@ -50,6 +105,13 @@ func addBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = checkMember(booking)
+	if err != nil {
+		log.Warn("Tried to book with non-existing member id")
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	class, err := classes.GetClassByID(booking.ClassID)
 	if err != nil {
 		log.Warn("Tried to book with non-existing class id")
@@ -57,18 +119,57 @@ func addBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = checkValidity(booking, class)
+	calendar, err := getCalendar(booking)
+	if err != nil {
+		log.Error("Error fetching class calendar from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	err = checkValidity(booking, class, calendar)
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = db.Create(&booking).Error
+	err = db.saveBooking(&booking, nil)
+	if err == ErrClassFull {
+		log.Warnf("Class %d is full on %s", booking.ClassID, booking.BookingDate.Format("2006-01-02"))
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err == ErrAlreadyBooked {
+		log.Warnf("Member %d has already booked class %d on %s", booking.MemberID, booking.ClassID, booking.BookingDate.Format("2006-01-02"))
+		respondAlreadyBooked(w, &booking)
+		return
+	}
+	if err == ErrNotCovered {
+		helpers.ResponseJSON(w, http.StatusPaymentRequired, err.Error())
+		return
+	}
+	if err == payments.ErrNoProvider {
+		log.Warn("Tried to book a session sold on its own without a payment provider")
+		helpers.ResponseJSON(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if promotions.IsRedeemError(err) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		log.Error("Error inserting booking to db: ", err)
 		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
+	err = openPayment(&booking)
+	if err != nil {
+		log.Errorf("Error opening payment of booking %d with provider: %s", booking.ID, err)
+		helpers.ResponseJSON(w, http.StatusBadGateway, paymentNotOpened)
+		return
+	}
+	notifyBooking(notify.EventBookingCreated, &booking)
+	emitBooking(webhooks.EventBookingCreated, &booking)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(&booking)
 }
@@ -79,6 +180,15 @@ func getBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	flagged := []Booking{*booking}
+	err = db.flagCancelled(flagged)
+	if err != nil {
+		log.Error("Error fetching class exceptions from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	booking = &flagged[0]
+
 	json.NewEncoder(w).Encode(&booking)
 }
 
@@ -87,6 +197,12 @@ func updateBooking(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	previous := *booking
+
+	if booking.Status != StatusPending && booking.Status != StatusConfirmed {
+		helpers.ResponseJSON(w, http.StatusConflict, "Only pending or confirmed bookings can be changed")
+		return
+	}
 
 	err = json.NewDecoder(r.Body).Decode(&booking)
 	if err != nil {
@@ -94,48 +210,207 @@ func updateBooking(w http.ResponseWriter, r *http.Request) {
 		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for booking")
 		return
 	}
+	// Coverage is only ever set when covering the booking
+	booking.MembershipID, booking.PassID, booking.InvoiceID, booking.RedemptionID = previous.MembershipID, previous.PassID, previous.InvoiceID, previous.RedemptionID
+
+	err = checkMember(*booking)
+	if err != nil {
+		log.Warn("Tried to book with non-existing member id")
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	class, err := classes.GetClassByID(booking.ClassID)
 
-	err = checkValidity(*booking, class)
+	calendar, err := getCalendar(*booking)
+	if err != nil {
+		log.Error("Error fetching class calendar from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	err = checkValidity(*booking, class, calendar)
 	if err != nil {
 		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = db.Save(&booking).Error
+	err = db.saveBooking(booking, &previous)
+	if err == ErrClassFull {
+		log.Warnf("Class %d is full on %s", booking.ClassID, booking.BookingDate.Format("2006-01-02"))
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err == ErrAlreadyBooked {
+		log.Warnf("Member %d has already booked class %d on %s", booking.MemberID, booking.ClassID, booking.BookingDate.Format("2006-01-02"))
+		respondAlreadyBooked(w, booking)
+		return
+	}
+	if err == ErrNotCovered {
+		helpers.ResponseJSON(w, http.StatusPaymentRequired, err.Error())
+		return
+	}
+	if err == payments.ErrNoProvider {
+		log.Warn("Tried to book a session sold on its own without a payment provider")
+		helpers.ResponseJSON(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if promotions.IsRedeemError(err) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		log.Error("Error saving booking to db: ", err)
 		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
+	err = openPayment(booking)
+	if err != nil {
+		log.Errorf("Error opening payment of booking %d with provider: %s", booking.ID, err)
+		helpers.ResponseJSON(w, http.StatusBadGateway, paymentNotOpened)
+		return
+	}
 
 	json.NewEncoder(w).Encode(&booking)
 }
+
+// Handler moving the requested booking to status
+func transitionBooking(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		booking, err := db.getBookingFromReq(w, r)
+		if err != nil {
+			return
+		}
+
+		err = db.transition(booking, status)
+		if _, ok := err.(*TransitionError); ok {
+			helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			log.Errorf("Error changing booking %d to %s in db: %s", booking.ID, status, err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+		if status == StatusCancelled {
+			notifyBooking(notify.EventBookingCancelled, booking)
+			emitBooking(webhooks.EventBookingCancelled, booking)
+		}
+
+		json.NewEncoder(w).Encode(&booking)
+	}
+}
+
+// Bookings are kept for their history, so deleting one cancels it
 func deleteBooking(w http.ResponseWriter, r *http.Request) {
 	booking, err := db.getBookingFromReq(w, r)
 	if err != nil {
 		return
 	}
 
-	err = db.Delete(&booking).Error
+	err = db.transition(booking, StatusCancelled)
+	if _, ok := err.(*TransitionError); ok {
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error cancelling booking in db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	notifyBooking(notify.EventBookingCancelled, booking)
+	emitBooking(webhooks.EventBookingCancelled, booking)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Booking cancelled",
+		"cancellation": booking.Cancellation,
+	})
+}
+
+func moveExceptionBookings(w http.ResponseWriter, r *http.Request) {
+	_, exception, err := classes.GetExceptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	if exception.MovedTo == nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Session is cancelled without a date to move bookings to")
+		return
+	}
+
+	moved, err := db.moveBookings(*exception)
+	if err == ErrClassFull {
+		log.Warnf("Class %d has no room on %s for bookings of %s", exception.ClassID, exception.MovedTo.Format("2006-01-02"), exception.Date.Format("2006-01-02"))
+		helpers.ResponseJSON(w, http.StatusConflict, "Class doesn't have room for all the moved bookings on the new date")
+		return
+	}
+	if err == ErrAlreadyBooked {
+		helpers.ResponseJSON(w, http.StatusConflict, "Some members have already booked the new date")
+		return
+	}
+	if err != nil {
+		log.Error("Error moving bookings in db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&moved)
+}
+
+func getMemberBookings(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var bookings []Booking
+	err = db.Where("member_id = ?", member.ID).Order("booking_date ASC").Find(&bookings).Error
 	if err != nil {
-		log.Error("Error deleting booking from db: ", err)
+		log.Error("Error fetching bookings of member from db: ", err)
 		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
 
-	helpers.ResponseJSON(w, 200, "Booking removed")
+	err = db.flagCancelled(bookings)
+	if err != nil {
+		log.Error("Error fetching class exceptions from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&bookings)
+}
+
+// MemberRoutes set routes for bookings under /members
+func MemberRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/bookings", getMemberBookings).Methods("GET")
+}
+
+// ClassRoutes set routes for bookings under /classes
+func ClassRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/exceptions/{exceptionID}/move-bookings", moveExceptionBookings).Methods("POST")
+	router.HandleFunc("/{id}/enrollments", enrollToClass).Methods("POST")
+	router.HandleFunc("/{id}/sessions/{date}/roster", getRoster).Methods("GET")
+	router.HandleFunc("/{id}/sessions/{date}/roster", markRoster).Methods("POST")
 }
 
-// Routes set routes for /bookings
+// Routes set routes for /bookings. The bookings table is created in migrations.
 func Routes(gormDB *gorm.DB, router *mux.Router) {
 	db = Database{gormDB}
-	db.AutoMigrate(&Booking{})
+	payments.OnSettling(lockBookedClass)
+	payments.OnSettled(settleBooking)
 
 	router.HandleFunc("", getBookings).Methods("GET")
 	router.HandleFunc("", addBooking).Methods("POST")
 	router.HandleFunc("/{id}", getBooking).Methods("GET")
 	router.HandleFunc("/{id}", updateBooking).Methods("PUT")
 	router.HandleFunc("/{id}", deleteBooking).Methods("DELETE")
+	router.HandleFunc("/{id}/confirm", transitionBooking(StatusConfirmed)).Methods("POST")
+	router.HandleFunc("/{id}/cancel", transitionBooking(StatusCancelled)).Methods("POST")
+	router.HandleFunc("/{id}/check-in", transitionBooking(StatusAttended)).Methods("POST")
+	router.HandleFunc("/{id}/no-show", transitionBooking(StatusNoShow)).Methods("POST")
 }
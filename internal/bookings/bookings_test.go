@@ -2,6 +2,7 @@ package bookings
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -10,10 +11,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	mocket "github.com/selvatico/go-mocket"
 	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/members"
+	"github.com/teeaa/studio/internal/payments"
 )
 
 func setup() {
@@ -21,6 +25,9 @@ func setup() {
 	mocket.Catcher.Logging = true
 	gormDB, _ := gorm.Open(mocket.DriverName, "")
 	db = Database{gormDB}
+	members.SetupExternally(members.Database(db))
+	payments.SetupExternally(payments.Database(db))
+	payments.SetProvider(payments.NewFakeProvider())
 }
 
 // Mock get class by id response for checking existing classes to book to
@@ -35,6 +42,32 @@ func setClassMatch() {
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply(commonReply)
 }
 
+// Mock get member by id response for the booking member
+func setMemberMatch() {
+	commonReply := []map[string]interface{}{{
+		"id":   5,
+		"name": "Another Tester",
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"  WHERE ("members"."id" = 5) ORDER BY "members"."id" ASC LIMIT 1`).WithReply(commonReply)
+}
+
+// Mock the booking member holding membership 2 which covers the booking
+func setMembershipMatch() {
+	commonReply := []map[string]interface{}{{
+		"id":         2,
+		"member_id":  5,
+		"start_date": time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		"status":     "active",
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "memberships"  WHERE (member_id = 5`).WithReply(commonReply)
+}
+
+// Mock the amount of existing bookings counted against class capacity
+func setBookedCount(count int) {
+	commonReply := []map[string]interface{}{{"count(*)": count}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply(commonReply)
+}
+
 func TestGetBookingsEmpty(t *testing.T) {
 	setup()
 	commonReply := []map[string]interface{}{{}}
@@ -64,13 +97,16 @@ func TestGetBookingsEmpty(t *testing.T) {
 
 func TestAddBooking(t *testing.T) {
 	setClassMatch()
+	setMemberMatch()
+	setMembershipMatch()
+	setBookedCount(0)
 	mocket.Catcher.NewMock().WithQuery(`INSERT INTO "bookings"`)
 
 	requestData := Booking{
-		123, // Sent ID shouldn't affect result
-		"Another Tester",
-		time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          123, // Sent ID shouldn't affect result
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
 	}
 
 	w, r, _ := makeRequest(&requestData, nil)
@@ -92,21 +128,529 @@ func TestAddBooking(t *testing.T) {
 		t.Error("Failed to unmarshalling response to json:", err)
 	}
 
+	var covered map[string]interface{}
+	json.Unmarshal(body, &covered)
+	if covered["membership_id"] != float64(2) {
+		t.Error("Expected booking to be covered by the membership:", covered)
+	}
+
 	compare := Booking{
-		0,
-		"Another Tester",
-		time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          0,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
 	}
 	if compare != booking {
 		t.Error("Received booking data didn't match expectations:", compare, booking)
 	}
 }
 
+func TestAddBookingNotCovered(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addBooking(w, r)
+
+	if w.Code != http.StatusPaymentRequired {
+		t.Errorf("Expected HTTP status 402, got %d instead", w.Code)
+	}
+}
+
+// Mock class 1 sold on its own at 15.00 including 10% VAT
+func setPricedClassMatch() {
+	commonReply := []map[string]interface{}{{
+		"id":         1,
+		"name":       "Class #1",
+		"start_date": time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+		"price":      1500,
+		"vat_rate":   10,
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1)`).WithReply(commonReply)
+}
+
+// Mock the invoice sequence at the number of the latest invoice
+func setInvoiceSequence(last int) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "invoice_sequence"`).WithReply([]map[string]interface{}{{"id": 1, "last_number": last}})
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "invoice_sequence"`).WithRowsNum(1)
+}
+
+func TestAddBookingInvoiced(t *testing.T) {
+	mocket.Catcher.Reset()
+	setPricedClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
+	setInvoiceSequence(41)
+	var invoiceNumber interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "invoices"`).WithID(7).WithCallback(func(query string, args []driver.NamedValue) {
+		invoiceNumber = args[0].Value
+	})
+	var line []driver.NamedValue
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "invoice_lines"`).WithCallback(func(query string, args []driver.NamedValue) {
+		line = args
+	})
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	classes.SetupExternally(classes.Database(db))
+	addBooking(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var invoiced map[string]interface{}
+	json.Unmarshal(body, &invoiced)
+	payment, _ := invoiced["payment"].(map[string]interface{})
+	if invoiced["invoice_id"] != float64(7) || invoiced["status"] != StatusPending || payment["amount"] != float64(1500) {
+		t.Error("Expected booking to be invoiced and pending for its payment:", invoiced)
+	}
+	if payment["intent_id"] == nil || payment["client_secret"] == nil {
+		t.Error("Expected payment to be opened at the provider once the booking was saved:", payment)
+	}
+	if invoiceNumber != int64(42) {
+		t.Error("Expected invoice to be numbered next in the sequence, got:", invoiceNumber)
+	}
+	// invoice_id, description, quantity, unit_price, discount, vat_rate, net, vat, total
+	if len(line) != 9 || line[1].Value != "Class #1 on 2019-08-11" || line[3].Value != int64(1500) ||
+		line[6].Value != int64(1364) || line[7].Value != int64(136) || line[8].Value != int64(1500) {
+		t.Error("Invoice line didn't match expectations:", line)
+	}
+}
+
+func TestAddBookingWithCode(t *testing.T) {
+	mocket.Catcher.Reset()
+	setPricedClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "discount_codes"  WHERE (code = SUMMER)`).WithReply([]map[string]interface{}{{
+		"id":     2,
+		"code":   "SUMMER",
+		"kind":   "percent",
+		"amount": 20,
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "discount_code_classes"`).WithReply([]map[string]interface{}{{"count(*)": 0}})
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "discount_codes"`).WithRowsNum(1)
+	setInvoiceSequence(0)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "invoices"`).WithID(7)
+	var line []driver.NamedValue
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "invoice_lines"`).WithCallback(func(query string, args []driver.NamedValue) {
+		line = args
+	})
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+		Code:        " summer",
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	classes.SetupExternally(classes.Database(db))
+	addBooking(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var discounted map[string]interface{}
+	json.Unmarshal(body, &discounted)
+	discount, _ := discounted["discount"].(map[string]interface{})
+	payment, _ := discounted["payment"].(map[string]interface{})
+	if discount["code"] != "SUMMER" || discount["discount"] != float64(300) || payment["amount"] != float64(1200) {
+		t.Error("Expected discount to be applied to the booking:", discounted)
+	}
+	// invoice_id, description, quantity, unit_price, discount, vat_rate, net, vat, total
+	if len(line) != 9 || line[4].Value != int64(300) || line[8].Value != int64(1200) {
+		t.Error("Expected discount on the invoice line:", line)
+	}
+}
+
+func TestAddBookingWithUnknownCode(t *testing.T) {
+	mocket.Catcher.Reset()
+	setPricedClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+		Code:        "WINTER",
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addBooking(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestAddBookingCoveredWithCode(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setMembershipMatch()
+	setBookedCount(0)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "discount_codes"  WHERE (code = SUMMER)`).WithReply([]map[string]interface{}{{
+		"id":     2,
+		"code":   "SUMMER",
+		"kind":   "percent",
+		"amount": 20,
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "discount_code_classes"`).WithReply([]map[string]interface{}{{"count(*)": 0}})
+	redeemed := 0
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "discount_codes"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		redeemed++
+	})
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+		Code:        "summer",
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addBooking(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var booking map[string]interface{}
+	json.Unmarshal(body, &booking)
+	notice, _ := booking["code_notice"].(string)
+	if booking["discount"] != nil || redeemed != 0 || !strings.Contains(notice, "SUMMER wasn't applied") {
+		t.Error("Expected the code to be left unused and told so:", string(body))
+	}
+
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setMembershipMatch()
+	setBookedCount(0)
+
+	requestData.Code = "WINTER"
+	w, r, _ = makeRequest(&requestData, nil)
+	addBooking(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown code to be rejected with HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestAddBookingClassFull(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(20)
+
+	requestData := Booking{
+		ID:          0,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	classes.SetupExternally(classes.Database(db))
+	addBooking(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestAddBookingWrongWeekday(t *testing.T) {
+	commonReply := []map[string]interface{}{{
+		"id":         2,
+		"name":       "Class #2",
+		"start_date": time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+		"weekdays":   "MO,WE",
+	}}
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 2) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply(commonReply)
+	setMemberMatch()
+	setBookedCount(0)
+
+	// 2019-08-11 is a Sunday
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     2,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	classes.SetupExternally(classes.Database(db))
+	addBooking(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestAddBookingCancelledSession(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_exceptions"  WHERE (class_id = 1 AND (date BETWEEN 2019-08-11`).WithReply([]map[string]interface{}{{
+		"id":       4,
+		"class_id": 1,
+		"date":     time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"reason":   "Teacher sick",
+	}})
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	classes.SetupExternally(classes.Database(db))
+	addBooking(w, r)
+
+	body, _ := ioutil.ReadAll(w.Body)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+	if !strings.Contains(string(body), "Teacher sick") {
+		t.Error("Expected rejection to tell the session is cancelled:", string(body))
+	}
+}
+
+func TestAddBookingBlackout(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "blackouts"`).WithReply([]map[string]interface{}{{
+		"id":         2,
+		"name":       "Summer break",
+		"start_date": time.Date(2019, 8, 5, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 18, 0, 0, 0, 0, time.UTC),
+	}})
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	classes.SetupExternally(classes.Database(db))
+	addBooking(w, r)
+
+	body, _ := ioutil.ReadAll(w.Body)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+	if !strings.Contains(string(body), "Summer break (blackout 2)") {
+		t.Error("Expected rejection to name the blackout:", string(body))
+	}
+}
+
+func TestMoveExceptionBookings(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setBookedCount(0)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_exceptions"  WHERE (class_id = 1) AND ("class_exceptions"."id" = 4)`).WithReply([]map[string]interface{}{{
+		"id":       4,
+		"class_id": 1,
+		"date":     time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"moved_to": time.Date(2019, 8, 12, 0, 0, 0, 0, time.UTC),
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (class_id = 1 AND booking_date = 2019-08-11`).WithReply([]map[string]interface{}{
+		{"id": 1, "member_id": 5, "booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC), "class_id": 1},
+		{"id": 2, "member_id": 5, "booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC), "class_id": 1},
+	})
+	var moveQuery string
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings" SET "booking_date"`).WithCallback(func(query string, args []driver.NamedValue) {
+		moveQuery = query
+	})
+
+	w, r, _ := makeRequest(nil, map[string]string{"id": "1", "exceptionID": "4"})
+	classes.SetupExternally(classes.Database(db))
+	moveExceptionBookings(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+	if moveQuery == "" {
+		t.Error("Bookings weren't moved in the database")
+	}
+
+	var moved []map[string]interface{}
+	err = json.Unmarshal(body, &moved)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if len(moved) != 2 || moved[0]["booking_date"] != "2019-08-12" || moved[1]["booking_date"] != "2019-08-12" {
+		t.Error("Moved bookings didn't match expectations:", moved)
+	}
+}
+
+func TestMoveExceptionBookingsNoRoom(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setBookedCount(19)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_exceptions"  WHERE (class_id = 1) AND ("class_exceptions"."id" = 4)`).WithReply([]map[string]interface{}{{
+		"id":       4,
+		"class_id": 1,
+		"date":     time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"moved_to": time.Date(2019, 8, 12, 0, 0, 0, 0, time.UTC),
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (class_id = 1 AND booking_date = 2019-08-11`).WithReply([]map[string]interface{}{
+		{"id": 1, "member_id": 5, "booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC), "class_id": 1},
+		{"id": 2, "member_id": 5, "booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC), "class_id": 1},
+	})
+
+	w, r, _ := makeRequest(nil, map[string]string{"id": "1", "exceptionID": "4"})
+	moveExceptionBookings(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestAddBookingNonExistingMember(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setBookedCount(0)
+
+	requestData := Booking{
+		MemberID:    55,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addBooking(w, r)
+
+	body, _ := ioutil.ReadAll(w.Body)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+	if !strings.Contains(string(body), "No such member") {
+		t.Error("Expected rejection to tell the member doesn't exist:", string(body))
+	}
+}
+
+func TestAddBookingAlreadyBooked(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setMembershipMatch()
+	setBookedCount(3)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "bookings"`).WithError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (member_id = 5 AND class_id = 1 AND booking_date = 2019-08-11`).WithReply([]map[string]interface{}{{
+		"id":           9,
+		"member_id":    5,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"class_id":     1,
+	}})
+
+	requestData := Booking{
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addBooking(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+
+	var responseBody map[string]interface{}
+	err = json.Unmarshal(body, &responseBody)
+	if err != nil {
+		t.Error("Error unmarshalling body:", err)
+	}
+	if responseBody["booking_id"] != float64(9) {
+		t.Error("Expected conflict to point to the existing booking:", responseBody)
+	}
+}
+
+func TestGetMemberBookings(t *testing.T) {
+	mocket.Catcher.Reset()
+	setMemberMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (member_id = 5) ORDER BY booking_date ASC`).WithReply([]map[string]interface{}{
+		{"id": 1, "member_id": 5, "booking_date": time.Date(2019, 7, 2, 0, 0, 0, 0, time.UTC), "class_id": 1},
+		{"id": 4, "member_id": 5, "booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC), "class_id": 2},
+	})
+
+	w, r, _ := makeRequest(nil, map[string]string{"id": "5"})
+	getMemberBookings(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var bookings []Booking
+	err = json.Unmarshal(body, &bookings)
+	if err != nil {
+		t.Error("Failed to serialise response to json:", err)
+	}
+	if len(bookings) != 2 || bookings[0].MemberID != 5 || bookings[1].ClassID != 2 {
+		t.Error("Received member bookings didn't match expectations:", bookings)
+	}
+}
+
 func TestGetBookingsData(t *testing.T) {
 	commonReply := []map[string]interface{}{{
 		"id":           1,
-		"name":         "Another Tester",
+		"member_id":    5,
 		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
 		"class_id":     1,
 	}}
@@ -125,10 +669,10 @@ func TestGetBookingsData(t *testing.T) {
 	}
 
 	compare, _ := json.Marshal([]Booking{{
-		1,
-		"Another Tester",
-		time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          1,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
 	}})
 
 	// Need to compare response without Unmarshal because that would reset ids
@@ -142,24 +686,27 @@ func TestGetBookingsData(t *testing.T) {
 func TestPutBooking(t *testing.T) {
 	commonReply := []map[string]interface{}{{
 		"id":           1,
-		"name":         "Old tester name",
+		"member_id":    5,
 		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
 		"class_id":     1,
+		"status":       StatusConfirmed,
 	}}
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE ("bookings"."id" = 1) ORDER BY "bookings"."id" ASC LIMIT 1`).WithReply(commonReply)
-	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings" SET "name" = ?, "booking_date" = ?, "class_id" = ?  WHERE "bookings"."id" = ?`)
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings" SET "member_id" = ?, "booking_date" = ?, "class_id" = ?  WHERE "bookings"."id" = ?`)
 
 	requestData := Booking{
-		123, // Sent ID shouldn't affect result
-		"New name",
-		time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          123, // Sent ID shouldn't affect result
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
 	}
 
-	commonReply[0]["name"] = requestData.Name
+	commonReply[0]["member_id"] = requestData.MemberID
 	commonReply[0]["booking_date"] = requestData.BookingDate
 	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE ("bookings"."id" = 1) ORDER BY "bookings"."id" ASC LIMIT 1`).WithReply(commonReply)
 	setClassMatch()
+	setMemberMatch()
+	setBookedCount(0)
 
 	w, r, _ := makeRequest(&requestData, map[string]string{"id": "1"})
 	updateBooking(w, r)
@@ -174,10 +721,10 @@ func TestPutBooking(t *testing.T) {
 	}
 
 	compare := Booking{
-		1,
-		"New name",
-		time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          1,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
 	}
 
 	var responseBooking Booking
@@ -193,7 +740,7 @@ func TestPutBooking(t *testing.T) {
 func TestDeleteBooking(t *testing.T) {
 	w, r, _ := makeRequest(nil, map[string]string{"id": "1"})
 
-	mocket.Catcher.NewMock().WithQuery(`DELETE FROM "boookings"  WHERE "bookings"."id" = ?`)
+	setStoredBooking(StatusConfirmed)
 	deleteBooking(w, r)
 
 	body, err := ioutil.ReadAll(w.Body)
@@ -205,13 +752,17 @@ func TestDeleteBooking(t *testing.T) {
 		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
 	}
 
-	var responseBody map[string]string
+	var responseBody struct {
+		Message      string       `json:"message"`
+		Cancellation Cancellation `json:"cancellation"`
+	}
 	err = json.Unmarshal(body, &responseBody)
 	if err != nil {
 		t.Error("Error unmarshalling body:", err)
 	}
 
-	if responseBody["message"] != "Booking removed" {
+	// The session is long past, so the cancellation is late
+	if responseBody.Message != "Booking cancelled" || !responseBody.Cancellation.Late || responseBody.Cancellation.Rule == "" {
 		t.Error("Response body didn't match expectations:", responseBody)
 	}
 }
@@ -231,10 +782,10 @@ func TestGetBooking(t *testing.T) {
 	}
 
 	compare := Booking{
-		0,
-		"New name",
-		time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          0,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
 	}
 
 	var responseBody Booking
@@ -260,10 +811,10 @@ func TestGetBookingNonExisting(t *testing.T) {
 
 func TestPutBookingNonExisting(t *testing.T) {
 	requestData := Booking{
-		234,
-		"Shouldn't work",
-		time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
-		122,
+		ID:          234,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
+		ClassID:     122,
 	}
 	w, r, _ := makeRequest(&requestData, map[string]string{"id": "234"})
 
@@ -286,13 +837,14 @@ func TestDeleteBookingNonExisting(t *testing.T) {
 
 func TestAddBookingNonExistingClass(t *testing.T) {
 	setClassMatch()
+	setMemberMatch()
 	mocket.Catcher.NewMock().WithQuery(`INSERT INTO "bookings"`)
 
 	requestData := Booking{
-		123, // Sent ID shouldn't affect result
-		"Another Tester",
-		time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
-		1234,
+		ID:          123, // Sent ID shouldn't affect result
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     1234,
 	}
 
 	w, r, _ := makeRequest(&requestData, nil)
@@ -306,13 +858,14 @@ func TestAddBookingNonExistingClass(t *testing.T) {
 
 func TestPutBookingNonExistingClass(t *testing.T) {
 	setClassMatch()
+	setMemberMatch()
 	mocket.Catcher.NewMock().WithQuery(`INSERT INTO "bookings"`)
 
 	requestData := Booking{
-		123, // Sent ID shouldn't affect result
-		"Another Tester",
-		time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
-		221,
+		ID:          123, // Sent ID shouldn't affect result
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		ClassID:     221,
 	}
 
 	w, r, _ := makeRequest(&requestData, nil)
@@ -338,3 +891,27 @@ func makeRequest(requestData *Booking, vars map[string]string) (*httptest.Respon
 
 	return w, r, nil
 }
+
+func TestWebhookPayload(t *testing.T) {
+	booking := Booking{ID: 1, Status: StatusPending, Payment: &payments.Payment{ID: 3, ClientSecret: "fake_pi_1_secret"}}
+
+	payload := webhookPayload(&booking)
+	if payload.Payment == nil || payload.Payment.ID != 3 || payload.Payment.ClientSecret != "" {
+		t.Error("Expected payment to be sent without its client secret, got:", payload.Payment)
+	}
+	if booking.Payment.ClientSecret != "fake_pi_1_secret" {
+		t.Error("Expected the booking itself to keep the client secret")
+	}
+}
+
+func TestUnmarshalReadOnlyFields(t *testing.T) {
+	var booking Booking
+	err := json.Unmarshal([]byte(`{"member_id": 5, "class_id": 1, "booking_date": "2019-08-11", "pass_id": 3, "membership_id": 2, "invoice_id": 7, "redemption_id": 9}`), &booking)
+	if err != nil {
+		t.Fatal("Error unmarshalling booking:", err)
+	}
+
+	if booking.PassID != nil || booking.MembershipID != nil || booking.InvoiceID != nil || booking.RedemptionID != nil {
+		t.Error("Expected coverage sent with the booking to be ignored:", booking)
+	}
+}
@@ -1,13 +1,23 @@
 package bookings
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
 	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/invoices"
+	"github.com/teeaa/studio/internal/memberships"
+	"github.com/teeaa/studio/internal/passes"
+	"github.com/teeaa/studio/internal/payments"
+	"github.com/teeaa/studio/internal/promotions"
+	"github.com/teeaa/studio/internal/webhooks"
 )
 
 // Database wrapper
@@ -17,6 +27,27 @@ type Database struct {
 
 var db Database
 
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// ErrClassFull is returned when the booked class has no spots left on the booking date
+var ErrClassFull = errors.New("Class is fully booked on the booking date")
+
+// ErrAlreadyBooked is returned when the member already has a booking for the class session
+var ErrAlreadyBooked = errors.New("Member has already booked the class on the booking date")
+
+// ErrNotCovered is returned when the member has neither a membership nor a pass valid for the booking,
+// and the session isn't sold on its own either
+var ErrNotCovered = errors.New("Member has no valid membership or pass for the booking date, and the session has no price")
+
+// ErrNeedsPayment is returned when a booking made while the member isn't around to pay would have to be paid online
+var ErrNeedsPayment = errors.New("Member has no valid membership or pass for the booking date, and the session has to be paid")
+
+// Told when the payment of a booking couldn't be opened at the provider, which cancels the booking
+const paymentNotOpened = "Payment provider failed to start the payment"
+
 // Get booking from database by id in request and handle error situations
 func (db *Database) getBookingFromReq(w http.ResponseWriter, r *http.Request) (*Booking, error) {
 	var booking Booking
@@ -41,3 +72,722 @@ func (db *Database) getBookingFromReq(w http.ResponseWriter, r *http.Request) (*
 	}
 	return &booking, nil
 }
+
+// Class session a booking is for, keying schedule exceptions
+type sessionKey struct {
+	classID uint64
+	date    string
+}
+
+// Flag bookings whose session has been cancelled or moved away from the booking date
+func (db *Database) flagCancelled(bookings []Booking) error {
+	classIDs := []uint64{}
+	dates := []time.Time{}
+	seenClasses := map[uint64]bool{}
+	seenDates := map[string]bool{}
+	for _, booking := range bookings {
+		if !seenClasses[booking.ClassID] {
+			seenClasses[booking.ClassID] = true
+			classIDs = append(classIDs, booking.ClassID)
+		}
+		date := classes.DateOf(booking.BookingDate)
+		if key := date.Format("2006-01-02"); !seenDates[key] {
+			seenDates[key] = true
+			dates = append(dates, date)
+		}
+	}
+
+	if len(classIDs) == 0 {
+		return nil
+	}
+
+	exceptions, err := classes.GetExceptionsOfClasses(db.DB, classIDs, dates)
+	if err != nil {
+		return err
+	}
+
+	excepted := map[sessionKey]bool{}
+	for _, exception := range exceptions {
+		excepted[sessionKey{exception.ClassID, classes.DateOf(exception.Date).Format("2006-01-02")}] = true
+	}
+
+	for i := range bookings {
+		if excepted[sessionKey{bookings[i].ClassID, classes.DateOf(bookings[i].BookingDate).Format("2006-01-02")}] {
+			bookings[i].SessionCancelled = true
+		}
+	}
+
+	return nil
+}
+
+// Move all bookings of a moved session to its new date in one transaction,
+// as long as the new date has room for all of them
+func (db *Database) moveBookings(exception classes.Exception) ([]Booking, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	class, err := classes.LockClassByID(tx, exception.ClassID)
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []Booking
+	err = tx.Where("class_id = ? AND booking_date = ? AND status <> ?", exception.ClassID, exception.Date, StatusCancelled).Find(&moved).Error
+	if err != nil {
+		return nil, err
+	}
+
+	booked, err := CountBooked(tx, exception.ClassID, *exception.MovedTo, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if booked+uint(len(moved)) > class.EffectiveCapacity() {
+		return nil, ErrClassFull
+	}
+
+	err = tx.Model(&Booking{}).
+		Where("class_id = ? AND booking_date = ? AND status <> ?", exception.ClassID, exception.Date, StatusCancelled).
+		Update("booking_date", *exception.MovedTo).Error
+	if helpers.IsDuplicateEntry(err) {
+		return nil, ErrAlreadyBooked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range moved {
+		moved[i].BookingDate = *exception.MovedTo
+	}
+
+	return moved, tx.Commit().Error
+}
+
+// SpotFreedHandler is called inside the transaction which released a spot from a class session
+type SpotFreedHandler func(tx *gorm.DB, classID uint64, date time.Time) error
+
+var spotFreedHandlers []SpotFreedHandler
+
+// OnSpotFreed register handler to be called whenever a booking leaves a class session
+func OnSpotFreed(handler SpotFreedHandler) {
+	spotFreedHandlers = append(spotFreedHandlers, handler)
+}
+
+func spotFreed(tx *gorm.DB, classID uint64, date time.Time) error {
+	for _, handler := range spotFreedHandlers {
+		err := handler(tx, classID, date)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindBooking find the active booking member has for a class session, leaving out booking excludeID
+func FindBooking(tx *gorm.DB, memberID uint64, classID uint64, date time.Time, excludeID uint64) (*Booking, error) {
+	var booking Booking
+	err := tx.Where("member_id = ? AND class_id = ? AND booking_date = ? AND id <> ? AND status <> ?", memberID, classID, date, excludeID, StatusCancelled).
+		First(&booking).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &booking, nil
+}
+
+// CountBooked count bookings taking up capacity of a class session, leaving out booking excludeID
+func CountBooked(tx *gorm.DB, classID uint64, date time.Time, excludeID uint64) (uint, error) {
+	var booked uint
+	err := tx.Model(&Booking{}).
+		Where("class_id = ? AND booking_date = ? AND id <> ? AND status <> ?", classID, date, excludeID, StatusCancelled).
+		Count(&booked).Error
+
+	return booked, err
+}
+
+// CreateBooking insert new booking inside transaction tx, covered by a membership of the
+// member, using up a credit of their pass or invoiced at the price of the session
+func CreateBooking(tx *gorm.DB, booking *Booking) error {
+	err := coverBooking(tx, booking, true)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(booking).Error
+}
+
+// CreateCoveredBooking insert new booking inside transaction tx like CreateBooking, for bookings
+// made without the member around to pay. Returns ErrNeedsPayment instead of leaving the booking
+// pending when the session would have to be paid online.
+func CreateCoveredBooking(tx *gorm.DB, booking *Booking) error {
+	err := coverBooking(tx, booking, false)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(booking).Error
+}
+
+// Cover new booking with a membership of the member valid for the class on the booking date,
+// or when they have none, with a credit of their pass. Failing both the session is invoiced
+// at its price when it's sold on its own, and the booking stays pending until the invoice is paid,
+// unless payOnline is false.
+func coverBooking(tx *gorm.DB, booking *Booking, payOnline bool) error {
+	membership, err := memberships.Covering(tx, booking.MemberID, booking.ClassID, booking.BookingDate)
+	if err != nil {
+		return err
+	}
+	if membership != nil {
+		booking.MembershipID = &membership.ID
+		return leaveCodeUnused(tx, booking, "membership")
+	}
+
+	pass, err := passes.UseCredit(tx, booking.MemberID, booking.ClassID, booking.BookingDate)
+	if err != nil {
+		return err
+	}
+	if pass != nil {
+		booking.PassID = &pass.ID
+		return leaveCodeUnused(tx, booking, "pass")
+	}
+
+	invoice, err := invoiceBooking(tx, booking, payOnline)
+	if err != nil {
+		return err
+	}
+
+	booking.InvoiceID = &invoice.ID
+	// Discounted to nothing, there's nothing left to pay
+	if invoice.Total == 0 {
+		return nil
+	}
+
+	payment, err := payments.Start(tx, invoice)
+	if err != nil {
+		return err
+	}
+
+	booking.Payment = payment
+	booking.Status = StatusPending
+	return nil
+}
+
+// Open the payment booking was left pending for at the provider, once the booking has been saved.
+// When that fails the payment is given up, cancelling the booking along with it.
+func openPayment(booking *Booking) error {
+	if booking.Payment == nil || booking.Payment.IntentID != nil {
+		return nil
+	}
+
+	err := payments.Open(booking.Payment)
+	if err != nil {
+		booking.Status = StatusCancelled
+	}
+	return err
+}
+
+// Check the discount code given with booking covered by a membership or pass, which leaves the
+// code unused, and tell so in the booking. An invalid code is rejected like when redeeming it.
+func leaveCodeUnused(tx *gorm.DB, booking *Booking, coveredBy string) error {
+	if booking.Code == "" {
+		return nil
+	}
+
+	err := promotions.Validate(tx, booking.Code, booking.ClassID)
+	if err != nil {
+		return err
+	}
+
+	booking.CodeNotice = fmt.Sprintf("Discount code %s wasn't applied, as the booking is covered by a %s", promotions.NormaliseCode(booking.Code), coveredBy)
+	return nil
+}
+
+// Tells if booking is covered by a membership, a pass or an invoice. Bookings made before
+// coverage was required have none.
+func covered(booking *Booking) bool {
+	return booking.MembershipID != nil || booking.PassID != nil || booking.InvoiceID != nil
+}
+
+// Tells if booking was moved to another session or member from previous
+func sessionChanged(previous *Booking, booking *Booking) bool {
+	return previous.ClassID != booking.ClassID || !previous.BookingDate.Equal(booking.BookingDate) || previous.MemberID != booking.MemberID
+}
+
+// Give back what covers booking inside transaction tx. The pass credit, the discount code
+// redemption and a paid invoice are given back only when refund is set, and a pending payment
+// is given up either way. Membership coverage holds nothing to give back.
+func releaseCoverage(tx *gorm.DB, booking *Booking, refund bool) error {
+	if refund && booking.PassID != nil {
+		err := passes.RefundCredit(tx, *booking.PassID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if refund && booking.RedemptionID != nil {
+		err := promotions.Release(tx, *booking.RedemptionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if booking.InvoiceID != nil {
+		err := payments.Cancel(tx, *booking.InvoiceID, refund)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cover booking moved to another session or member again inside transaction tx, as if it was
+// booked anew, so that its membership, pass or invoice always match what's booked. The old
+// coverage is given back first, including its discount code redemption, and a code given with
+// the update is redeemed like when booking. A booking which needs paying again waits pending
+// for its new payment, and a pending booking no longer needing one is confirmed.
+func recoverBooking(tx *gorm.DB, booking *Booking) error {
+	err := releaseCoverage(tx, booking, true)
+	if err != nil {
+		return err
+	}
+
+	wasPending := booking.Status == StatusPending
+	booking.MembershipID, booking.PassID, booking.InvoiceID, booking.RedemptionID, booking.Payment = nil, nil, nil, nil, nil
+	err = coverBooking(tx, booking, true)
+	if err != nil {
+		return err
+	}
+
+	if wasPending && booking.Payment == nil {
+		return booking.Transition(StatusConfirmed, time.Now().UTC())
+	}
+	return nil
+}
+
+// Issue invoice of the price of the booked session inside transaction tx, redeeming the discount
+// code given with the booking. Returns ErrNotCovered when the session has no price, and
+// ErrNeedsPayment when the discounted price isn't nothing and payOnline is false.
+func invoiceBooking(tx *gorm.DB, booking *Booking, payOnline bool) (*invoices.Invoice, error) {
+	class, err := classes.LockClassByID(tx, booking.ClassID)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := classes.PriceOn(tx, &class, booking.BookingDate)
+	if err != nil {
+		return nil, err
+	}
+	if price == nil {
+		return nil, ErrNotCovered
+	}
+
+	vatRate := invoices.VATRate()
+	if class.VATRate != nil {
+		vatRate = *class.VATRate
+	}
+
+	line := invoices.Line{
+		Description: fmt.Sprintf("%s on %s", class.Name, booking.BookingDate.Format("2006-01-02")),
+		Quantity:    1,
+		UnitPrice:   *price,
+		VATRate:     vatRate,
+	}
+
+	if booking.Code != "" {
+		redemption, err := promotions.Redeem(tx, booking.Code, booking.MemberID, booking.ClassID, booking.BookingDate, *price)
+		if err != nil {
+			return nil, err
+		}
+
+		booking.Discount = redemption
+		booking.RedemptionID = &redemption.ID
+		line.Discount = redemption.Discount
+		line.Description += fmt.Sprintf(" (discount code %s)", redemption.Code)
+	}
+
+	if !payOnline && line.Discount < line.UnitPrice {
+		return nil, ErrNeedsPayment
+	}
+
+	invoice := invoices.Invoice{
+		MemberID: booking.MemberID,
+		Lines:    []invoices.Line{line},
+	}
+	err = invoices.Issue(tx, &invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// Lock the class booked with the invoice of payment before the payment is locked to be settled,
+// in the same order as changes to bookings lock the class and then the payment
+func lockBookedClass(tx *gorm.DB, payment payments.Payment) error {
+	var booking Booking
+	err := tx.Where("invoice_id = ?", payment.InvoiceID).First(&booking).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = classes.LockClassByID(tx, booking.ClassID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
+// Confirm the pending booking of a paid invoice, or release its spot when the payment failed
+// or never arrived. Called inside the transaction settling the payment.
+func settleBooking(tx *gorm.DB, payment payments.Payment) error {
+	// Cancelled payments are given up by the booking itself, when it's cancelled or moved
+	if payment.Status == payments.StatusCancelled {
+		return nil
+	}
+
+	var found Booking
+	err := tx.Where("invoice_id = ?", payment.InvoiceID).First(&found).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Locked already when settled by the provider, see lockBookedClass
+	_, err = classes.LockClassByID(tx, found.ClassID)
+	classExists := err == nil
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	var booking Booking
+	err = tx.Set("gorm:query_option", "FOR UPDATE").Where("invoice_id = ?", payment.InvoiceID).First(&booking).Error
+	if err != nil {
+		return err
+	}
+
+	// Cancelled by the member, or confirmed by the studio, before the payment settled
+	if booking.Status != StatusPending {
+		return nil
+	}
+
+	status := StatusConfirmed
+	if payment.Status != payments.StatusSucceeded {
+		status = StatusCancelled
+	}
+
+	err = booking.Transition(status, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	err = tx.Save(&booking).Error
+	if err != nil {
+		return err
+	}
+
+	if status == StatusCancelled {
+		log.Infof("Released booking %d as its payment %d was %s", booking.ID, payment.ID, payment.Status)
+		if booking.RedemptionID != nil {
+			err = promotions.Release(tx, *booking.RedemptionID)
+			if err != nil {
+				return err
+			}
+		}
+		err = emitBookingTx(tx, webhooks.EventBookingCancelled, &booking)
+		if err != nil {
+			return err
+		}
+		if classExists {
+			return spotFreed(tx, booking.ClassID, booking.BookingDate)
+		}
+	}
+
+	return nil
+}
+
+// Save booking in a transaction which locks the booked class row first, so that
+// concurrent bookings to the same class are serialised and can't exceed its capacity.
+// When previous is given and the booking moved away from its session, the freed spot is handed on.
+func (db *Database) saveBooking(booking *Booking, previous *Booking) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	class, err := classes.LockClassByID(tx, booking.ClassID)
+	if err != nil {
+		return err
+	}
+
+	booked, err := CountBooked(tx, booking.ClassID, booking.BookingDate, booking.ID)
+	if err != nil {
+		return err
+	}
+
+	if booked >= class.EffectiveCapacity() {
+		return ErrClassFull
+	}
+
+	if booking.ID == 0 {
+		err = coverBooking(tx, booking, true)
+		if err != nil {
+			return err
+		}
+	} else if previous != nil && covered(previous) && sessionChanged(previous, booking) {
+		err = recoverBooking(tx, booking)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Save(booking).Error
+	if helpers.IsDuplicateEntry(err) {
+		return ErrAlreadyBooked
+	}
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && (previous.ClassID != booking.ClassID || !previous.BookingDate.Equal(booking.BookingDate)) {
+		err = spotFreed(tx, previous.ClassID, previous.BookingDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Move booking to status in a transaction which locks the booked class first, like all changes
+// to bookings of the class. The status is checked against the booking as stored once locked.
+// A cancelled booking is checked against the cancellation policy and hands its spot on inside
+// the same transaction, and gets its pass credit or payment back unless cancelled late.
+func (db *Database) transition(booking *Booking, status string) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	// Spots of a class which no longer exists can't be handed on, nor its policy applied
+	class, err := classes.LockClassByID(tx, booking.ClassID)
+	classExists := err == nil
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	err = tx.First(booking, booking.ID).Error
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if status == StatusCancelled && classExists {
+		cancellation := cancellationPolicy(class, *booking, now)
+		booking.Cancellation = &cancellation
+		booking.LateCancelled = cancellation.Late
+	}
+
+	err = booking.Transition(status, now)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Save(booking).Error
+	if err != nil {
+		return err
+	}
+
+	if status == StatusCancelled && classExists {
+		err = spotFreed(tx, booking.ClassID, booking.BookingDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if status == StatusCancelled {
+		err = releaseCoverage(tx, booking, !booking.LateCancelled)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Book member to every session of the class from enrollment.From to the end of the class in one
+// transaction, with the class row locked for the whole enrollment. Sessions which can't be booked
+// are listed as failed. Sessions moved away are left out, as they're booked on their new date.
+// Payments are opened at the provider only once committed, so nothing is left behind when
+// all or nothing is asked for and a session fails.
+func (db *Database) enroll(classID uint64, enrollment Enrollment) (EnrollmentResult, error) {
+	result := EnrollmentResult{Booked: []Booking{}, Failed: []FailedSession{}}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return result, tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	class, err := classes.LockClassByID(tx, classID)
+	if err != nil {
+		return result, err
+	}
+
+	from, to := classes.DateOf(enrollment.From), classes.DateOf(class.EndDate)
+	calendar, err := classes.GetCalendar(tx, classID, from, to)
+	if err != nil {
+		return result, err
+	}
+
+	for _, session := range class.Sessions(from, to, calendar) {
+		weekday := session.Date.Weekday()
+		if !session.MovedFrom.IsZero() {
+			weekday = session.MovedFrom.Weekday()
+		}
+		if !enrollment.Weekdays.Has(weekday) || !session.MovedTo.IsZero() {
+			continue
+		}
+
+		failed := FailedSession{Date: session.Date.Format("2006-01-02")}
+		if session.Cancelled {
+			failed.Reason = "Session is cancelled"
+			if session.Reason != "" {
+				failed.Reason += ": " + session.Reason
+			}
+			result.Failed = append(result.Failed, failed)
+			continue
+		}
+
+		_, err = FindBooking(tx, enrollment.MemberID, classID, session.Date, 0)
+		if err == nil {
+			failed.Reason = ErrAlreadyBooked.Error()
+			result.Failed = append(result.Failed, failed)
+			continue
+		}
+		if !gorm.IsRecordNotFoundError(err) {
+			return result, err
+		}
+
+		booked, err := CountBooked(tx, classID, session.Date, 0)
+		if err != nil {
+			return result, err
+		}
+		if booked >= class.EffectiveCapacity() {
+			failed.Reason = ErrClassFull.Error()
+			result.Failed = append(result.Failed, failed)
+			continue
+		}
+
+		booking := Booking{MemberID: enrollment.MemberID, BookingDate: session.Date, ClassID: classID}
+		err = CreateBooking(tx, &booking)
+		if err == ErrNotCovered {
+			failed.Reason = err.Error()
+			result.Failed = append(result.Failed, failed)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Booked = append(result.Booked, booking)
+	}
+
+	if enrollment.AllOrNothing && len(result.Failed) > 0 {
+		result.Booked = []Booking{}
+		return result, nil
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		return result, err
+	}
+
+	// Sessions whose payment can't be opened are cancelled, and fail after all
+	opened := []Booking{}
+	for _, booking := range result.Booked {
+		err = openPayment(&booking)
+		if err != nil {
+			log.Errorf("Error opening payment of booking %d with provider: %s", booking.ID, err)
+			result.Failed = append(result.Failed, FailedSession{Date: booking.BookingDate.Format("2006-01-02"), Reason: paymentNotOpened})
+			continue
+		}
+		opened = append(opened, booking)
+	}
+	result.Booked = opened
+
+	return result, nil
+}
+
+// Get bookings of a class session still holding their spot, in booking order
+func (db *Database) getSessionBookings(classID uint64, date time.Time) ([]Booking, error) {
+	var bookings []Booking
+	err := db.Where("class_id = ? AND booking_date = ? AND status <> ?", classID, date, StatusCancelled).
+		Order("id ASC").
+		Find(&bookings).Error
+
+	return bookings, err
+}
+
+// Mark attendance of bookings of a class session in one transaction, with the class row locked.
+// Either all marks are applied or, when any of them can't be, none and the failed marks are returned.
+func (db *Database) markAttendance(classID uint64, date time.Time, marks []AttendanceMark) ([]FailedMark, error) {
+	failed := []FailedMark{}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return failed, tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	_, err := classes.LockClassByID(tx, classID)
+	if err != nil {
+		return failed, err
+	}
+
+	now := time.Now().UTC()
+	for _, mark := range marks {
+		var booking Booking
+		err = tx.Where("class_id = ? AND booking_date = ?", classID, date).First(&booking, mark.BookingID).Error
+		if gorm.IsRecordNotFoundError(err) {
+			failed = append(failed, FailedMark{BookingID: mark.BookingID, Reason: "Booking is not for the session"})
+			continue
+		}
+		if err != nil {
+			return failed, err
+		}
+
+		status := StatusNoShow
+		if mark.Attended {
+			status = StatusAttended
+		}
+		if booking.Status == status {
+			continue
+		}
+
+		err = booking.Transition(status, now)
+		if err != nil {
+			failed = append(failed, FailedMark{BookingID: mark.BookingID, Reason: err.Error()})
+			continue
+		}
+
+		err = tx.Save(&booking).Error
+		if err != nil {
+			return failed, err
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed, nil
+	}
+
+	return failed, tx.Commit().Error
+}
@@ -1,8 +1,13 @@
 package bookings
 
 import (
+	"database/sql/driver"
 	"testing"
 	"time"
+
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/payments"
 )
 
 func TestGetBookingFromReq(t *testing.T) {
@@ -13,12 +18,350 @@ func TestGetBookingFromReq(t *testing.T) {
 		t.Error("Error getting booking by request vars")
 	}
 	compare := &Booking{
-		1,
-		"New name",
-		time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
-		1,
+		ID:          1,
+		MemberID:    5,
+		BookingDate: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		ClassID:     1,
+		Status:      StatusConfirmed,
 	}
 	if *compare != *booking {
 		t.Error("Retrieved class data didn't match expectations:", *compare, *booking)
 	}
 }
+
+// Mock the stored booking 1 with status, and saving changes to it
+func setStoredBooking(status string) {
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings"`).WithRowsNum(1)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE "bookings"."id" = 1`).WithReply([]map[string]interface{}{{
+		"id":           1,
+		"member_id":    5,
+		"booking_date": time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		"class_id":     1,
+		"status":       status,
+	}})
+}
+
+func TestCancelBookingFreesSpot(t *testing.T) {
+	defer func() { spotFreedHandlers = nil }()
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setStoredBooking(StatusConfirmed)
+
+	var freedClassID uint64
+	var freedDate time.Time
+	OnSpotFreed(func(tx *gorm.DB, classID uint64, date time.Time) error {
+		freedClassID = classID
+		freedDate = date
+		return nil
+	})
+
+	booking := Booking{ID: 1, ClassID: 1}
+	err := db.transition(&booking, StatusCancelled)
+	if err != nil {
+		t.Error("Error cancelling booking:", err)
+	}
+
+	if booking.Status != StatusCancelled || booking.CancelledAt == nil {
+		t.Error("Cancellation wasn't recorded on the booking:", booking)
+	}
+	if freedClassID != 1 || !freedDate.Equal(booking.BookingDate) {
+		t.Error("Freed spot wasn't handed on:", freedClassID, freedDate)
+	}
+}
+
+func TestNoShowKeepsSpot(t *testing.T) {
+	defer func() { spotFreedHandlers = nil }()
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setStoredBooking(StatusConfirmed)
+
+	freed := false
+	OnSpotFreed(func(tx *gorm.DB, classID uint64, date time.Time) error {
+		freed = true
+		return nil
+	})
+
+	booking := Booking{ID: 1, ClassID: 1}
+	err := db.transition(&booking, StatusNoShow)
+	if err != nil {
+		t.Error("Error marking booking as no-show:", err)
+	}
+
+	if booking.Status != StatusNoShow || booking.NoShowAt == nil || freed {
+		t.Error("Expected no-show to be recorded without freeing the spot:", booking)
+	}
+}
+
+func TestTransitionFromCancelled(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setStoredBooking(StatusCancelled)
+
+	booking := Booking{ID: 1, ClassID: 1}
+	err := db.transition(&booking, StatusAttended)
+	if _, ok := err.(*TransitionError); !ok {
+		t.Error("Expected checking in a cancelled booking to be rejected, got:", err)
+	}
+}
+
+// Mock the stored booking 1 paid with pass 3, and the pass with a credit used
+func setStoredPassBooking(status string) *[]interface{} {
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings"`).WithRowsNum(1)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE "bookings"."id" = 1`).WithReply([]map[string]interface{}{{
+		"id":           1,
+		"member_id":    5,
+		"booking_date": time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		"class_id":     1,
+		"status":       status,
+		"pass_id":      3,
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "passes"`).WithReply([]map[string]interface{}{{
+		"id":        3,
+		"member_id": 5,
+		"credits":   10,
+		"remaining": 4,
+	}})
+
+	refunds := []interface{}{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "passes"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		refunds = append(refunds, args[0].Value)
+	})
+	return &refunds
+}
+
+func TestCancelBookingRefundsCredit(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	refunds := setStoredPassBooking(StatusPending)
+
+	booking := Booking{ID: 1, ClassID: 1}
+	err := db.transition(&booking, StatusCancelled)
+	if err != nil {
+		t.Error("Error cancelling booking:", err)
+	}
+
+	if len(*refunds) != 1 || (*refunds)[0] != int64(5) {
+		t.Error("Expected the pass credit to be refunded, got:", *refunds)
+	}
+}
+
+func TestMoveBookingUsesNewCredit(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setBookedCount(0)
+	credits := setStoredPassBooking(StatusConfirmed)
+
+	passID := uint64(3)
+	previous := Booking{ID: 1, MemberID: 5, ClassID: 1, BookingDate: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC), Status: StatusConfirmed, PassID: &passID}
+	booking := previous
+	booking.BookingDate = time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC)
+	err := db.saveBooking(&booking, &previous)
+	if err != nil {
+		t.Fatal("Error moving booking:", err)
+	}
+
+	// The old credit is refunded and a credit used again for the new date
+	if len(*credits) != 2 || (*credits)[0] != int64(5) || (*credits)[1] != int64(3) {
+		t.Error("Expected the pass credit to be refunded and used again, got:", *credits)
+	}
+	if booking.PassID == nil || *booking.PassID != 3 || booking.Status != StatusConfirmed {
+		t.Error("Expected moved booking to be covered by the pass again:", booking)
+	}
+}
+
+func TestLateCancelKeepsCredit(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	refunds := setStoredPassBooking(StatusConfirmed)
+
+	booking := Booking{ID: 1, ClassID: 1}
+	err := db.transition(&booking, StatusCancelled)
+	if err != nil {
+		t.Error("Error cancelling booking:", err)
+	}
+
+	if !booking.LateCancelled || len(*refunds) != 0 {
+		t.Error("Expected late cancellation to keep the pass credit used, refunded:", *refunds)
+	}
+}
+
+// Mock the stored booking 1 with status, invoiced with invoice 7, and record saved statuses
+func setStoredInvoicedBooking(status string) *[]interface{} {
+	saved := []interface{}{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		// member_id, booking_date, class_id, status, ...
+		saved = append(saved, args[3].Value)
+	})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (invoice_id = 7)`).WithReply([]map[string]interface{}{{
+		"id":           1,
+		"member_id":    5,
+		"booking_date": time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		"class_id":     1,
+		"status":       status,
+		"invoice_id":   7,
+	}})
+	return &saved
+}
+
+func TestSettleBookingPaid(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	saved := setStoredInvoicedBooking(StatusPending)
+
+	err := settleBooking(db.DB, payments.Payment{InvoiceID: 7, Status: payments.StatusSucceeded})
+	if err != nil {
+		t.Error("Error settling booking:", err)
+	}
+
+	if len(*saved) != 1 || (*saved)[0] != StatusConfirmed {
+		t.Error("Expected paid booking to be confirmed, saved:", *saved)
+	}
+}
+
+func TestSettleBookingReleased(t *testing.T) {
+	defer func() { spotFreedHandlers = nil }()
+	mocket.Catcher.Reset()
+	setClassMatch()
+	saved := setStoredInvoicedBooking(StatusPending)
+
+	freed := 0
+	OnSpotFreed(func(tx *gorm.DB, classID uint64, date time.Time) error {
+		freed++
+		return nil
+	})
+
+	err := settleBooking(db.DB, payments.Payment{InvoiceID: 7, Status: payments.StatusExpired})
+	if err != nil {
+		t.Error("Error settling booking:", err)
+	}
+
+	if len(*saved) != 1 || (*saved)[0] != StatusCancelled || freed != 1 {
+		t.Error("Expected unpaid booking to be released, saved:", *saved, freed)
+	}
+}
+
+func TestSettleBookingAlreadyCancelled(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	saved := setStoredInvoicedBooking(StatusCancelled)
+
+	err := settleBooking(db.DB, payments.Payment{InvoiceID: 7, Status: payments.StatusCancelled})
+	if err != nil {
+		t.Error("Error settling booking:", err)
+	}
+
+	if len(*saved) != 0 {
+		t.Error("Expected cancelled booking to be left alone, saved:", *saved)
+	}
+}
+
+// Mock redemption 9 of discount code 2 redeemed 3 times, and record the redemption counts saved
+// and the redemptions deleted
+func setRedemption() (*[]interface{}, *int) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "redemptions"`).WithReply([]map[string]interface{}{{"id": 9, "discount_code_id": 2, "code": "SUMMER"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "discount_codes"`).WithReply([]map[string]interface{}{{"id": 2, "code": "SUMMER", "redemptions": 3}})
+	counts := []interface{}{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "discount_codes"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		counts = append(counts, args[0].Value)
+	})
+	deleted := 0
+	mocket.Catcher.NewMock().WithQuery(`DELETE FROM "redemptions"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		deleted++
+	})
+	return &counts, &deleted
+}
+
+func TestReleaseRedemption(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	counts, deleted := setRedemption()
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings"`).WithRowsNum(1)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE "bookings"."id" = 1`).WithReply([]map[string]interface{}{{
+		"id":            1,
+		"member_id":     5,
+		"booking_date":  time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		"class_id":      1,
+		"status":        StatusPending,
+		"invoice_id":    7,
+		"redemption_id": 9,
+	}})
+
+	booking := Booking{ID: 1, ClassID: 1}
+	err := db.transition(&booking, StatusCancelled)
+	if err != nil {
+		t.Error("Error cancelling booking:", err)
+	}
+	if len(*counts) != 1 || (*counts)[0] != int64(2) || *deleted != 1 {
+		t.Error("Expected cancelled booking to give its redemption back, saved counts and deletions:", *counts, *deleted)
+	}
+
+	mocket.Catcher.Reset()
+	setClassMatch()
+	counts, deleted = setRedemption()
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings"`).WithRowsNum(1)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (invoice_id = 7)`).WithReply([]map[string]interface{}{{
+		"id":            1,
+		"member_id":     5,
+		"booking_date":  time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC),
+		"class_id":      1,
+		"status":        StatusPending,
+		"invoice_id":    7,
+		"redemption_id": 9,
+	}})
+
+	err = settleBooking(db.DB, payments.Payment{InvoiceID: 7, Status: payments.StatusFailed})
+	if err != nil {
+		t.Error("Error settling booking:", err)
+	}
+	if len(*counts) != 1 || (*counts)[0] != int64(2) || *deleted != 1 {
+		t.Error("Expected booking released unpaid to give its redemption back, saved counts and deletions:", *counts, *deleted)
+	}
+}
+
+func TestBookingTransitions(t *testing.T) {
+	at := time.Date(2019, 8, 15, 18, 0, 0, 0, time.UTC)
+	booking := Booking{Status: StatusPending}
+
+	for _, status := range []string{StatusConfirmed, StatusAttended, StatusNoShow, StatusAttended} {
+		err := booking.Transition(status, at)
+		if err != nil {
+			t.Errorf("Expected change to %s to be allowed: %s", status, err)
+		}
+	}
+	if booking.ConfirmedAt == nil || booking.AttendedAt == nil || booking.NoShowAt == nil || booking.CancelledAt != nil {
+		t.Error("Transition times weren't stamped as expected:", booking)
+	}
+
+	if booking.Transition(StatusCancelled, at) == nil || booking.Transition(StatusPending, at) == nil {
+		t.Error("Expected attended booking not to be cancelled or made pending again")
+	}
+}
+
+func TestFlagCancelled(t *testing.T) {
+	var dates []driver.NamedValue
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "class_exceptions"  WHERE (class_id IN (1,2) AND date IN (`).WithReply([]map[string]interface{}{{
+		"id":       4,
+		"class_id": 1,
+		"date":     time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC),
+	}}).WithCallback(func(query string, args []driver.NamedValue) {
+		// class ids, then dates
+		dates = args[2:]
+	})
+
+	bookings := []Booking{
+		{ID: 1, BookingDate: time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC), ClassID: 1},
+		{ID: 2, BookingDate: time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC), ClassID: 2},
+		{ID: 3, BookingDate: time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), ClassID: 1},
+	}
+	err := db.flagCancelled(bookings)
+	if err != nil {
+		t.Error("Error flagging bookings:", err)
+	}
+
+	if !bookings[0].SessionCancelled || bookings[1].SessionCancelled || bookings[2].SessionCancelled {
+		t.Error("Only the booking on the cancelled session should be flagged:", bookings)
+	}
+	if len(dates) != 2 {
+		t.Error("Expected exceptions to be fetched for the two booked dates only, got:", dates)
+	}
+}
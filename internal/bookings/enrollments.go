@@ -0,0 +1,86 @@
+package bookings
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/notify"
+	"github.com/teeaa/studio/internal/payments"
+	"github.com/teeaa/studio/internal/promotions"
+	"github.com/teeaa/studio/internal/webhooks"
+)
+
+func enrollToClass(w http.ResponseWriter, r *http.Request) {
+	class, err := classes.GetClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var enrollment Enrollment
+	err = json.NewDecoder(r.Body).Decode(&enrollment)
+	if err != nil {
+		log.Warn("Error parsing JSON when enrolling to class: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for enrollment")
+		return
+	}
+
+	err = checkMember(Booking{MemberID: enrollment.MemberID})
+	if err != nil {
+		log.Warn("Tried to enroll with non-existing member id")
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if enrollment.From.IsZero() {
+		enrollment.From = classes.DateOf(time.Now().UTC())
+	}
+	if enrollment.From.After(class.EndDate) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Class ends before from")
+		return
+	}
+
+	result, err := db.enroll(class.ID, enrollment)
+	if err == ErrClassFull || err == ErrAlreadyBooked {
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err == ErrNotCovered {
+		helpers.ResponseJSON(w, http.StatusPaymentRequired, err.Error())
+		return
+	}
+	if err == payments.ErrNoProvider {
+		log.Warn("Tried to enroll to sessions sold on their own without a payment provider")
+		helpers.ResponseJSON(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if promotions.IsRedeemError(err) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error enrolling to class in db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	for i := range result.Booked {
+		notifyBooking(notify.EventBookingCreated, &result.Booked[i])
+		emitBooking(webhooks.EventBookingCreated, &result.Booked[i])
+	}
+
+	if len(result.Booked) == 0 && len(result.Failed) == 0 {
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Class has no sessions to book after from")
+		return
+	}
+
+	if len(result.Booked) == 0 {
+		w.WriteHeader(http.StatusConflict)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(&result)
+}
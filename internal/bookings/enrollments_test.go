@@ -0,0 +1,104 @@
+package bookings
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/classes"
+)
+
+// Mock enrolling to the Tuesdays of August, with 2019-08-27 fully booked
+func setEnrollmentMatch() {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setMembershipMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"  WHERE (class_id = 1 AND booking_date = 2019-08-27`).WithReply([]map[string]interface{}{{"count(*)": 20}})
+	setBookedCount(4)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "bookings"`).WithID(11)
+	classes.SetupExternally(classes.Database(db))
+}
+
+func TestEnrollToClass(t *testing.T) {
+	setEnrollmentMatch()
+
+	w, r := makeEnrollmentRequest(map[string]interface{}{"member_id": 5, "from": "2019-08-15", "weekdays": []string{"TU"}})
+	enrollToClass(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var result map[string][]map[string]interface{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if len(result["booked"]) != 1 || result["booked"][0]["booking_date"] != "2019-08-20" || result["booked"][0]["member_id"] != float64(5) {
+		t.Error("Booked sessions didn't match expectations:", result["booked"])
+	}
+	if len(result["failed"]) != 1 || result["failed"][0]["date"] != "2019-08-27" || result["failed"][0]["reason"] != ErrClassFull.Error() {
+		t.Error("Failed sessions didn't match expectations:", result["failed"])
+	}
+}
+
+func TestEnrollToClassAllOrNothing(t *testing.T) {
+	setEnrollmentMatch()
+
+	w, r := makeEnrollmentRequest(map[string]interface{}{"member_id": 5, "from": "2019-08-15", "weekdays": []string{"TU"}, "all_or_nothing": true})
+	enrollToClass(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+
+	var result map[string][]map[string]interface{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if len(result["booked"]) != 0 || len(result["failed"]) != 1 {
+		t.Error("Expected nothing to be booked when a session fails:", result)
+	}
+}
+
+func TestEnrollToClassNoSessions(t *testing.T) {
+	setEnrollmentMatch()
+
+	w, r := makeEnrollmentRequest(map[string]interface{}{"member_id": 5, "from": "2019-08-28", "weekdays": []string{"TU"}})
+	enrollToClass(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func makeEnrollmentRequest(requestData map[string]interface{}) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/classes/1/enrollments", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
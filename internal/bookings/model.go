@@ -3,15 +3,116 @@ package bookings
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/payments"
+	"github.com/teeaa/studio/internal/promotions"
+)
+
+// Booking statuses
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusCancelled = "cancelled"
+	StatusAttended  = "attended"
+	StatusNoShow    = "no_show"
 )
 
+// Statuses a booking can move to from each status. Attendance can be corrected afterwards.
+var transitions = map[string][]string{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusCancelled, StatusAttended, StatusNoShow},
+	StatusAttended:  {StatusNoShow},
+	StatusNoShow:    {StatusAttended},
+}
+
+// TransitionError is returned for a status change the booking lifecycle doesn't allow
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("Booking can't be changed from %s to %s", e.From, e.To)
+}
+
 // Booking representation of bookings.bookings
 type Booking struct {
-	ID          uint64    `gorm:"primary_key" json:"id"`
-	Name        string    `json:"name"`
-	BookingDate time.Time `gorm:"type:date" json:"booking_date"`
-	ClassID     uint64    `json:"class_id"`
+	ID          uint64     `gorm:"primary_key" json:"id"`
+	MemberID    uint64     `json:"member_id"`
+	BookingDate time.Time  `gorm:"type:date" json:"booking_date"`
+	ClassID     uint64     `json:"class_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+	AttendedAt  *time.Time `json:"attended_at"`
+	NoShowAt    *time.Time `json:"no_show_at"`
+	// Cancelled past the deadline of the cancellation policy, the booking still counts as used
+	LateCancelled bool `json:"late_cancelled"`
+	// Pass the booking used a credit of, or nil when it wasn't paid with a pass
+	PassID *uint64 `json:"pass_id"`
+	// Membership covering the booking, or nil when it wasn't covered by a membership
+	MembershipID *uint64 `json:"membership_id"`
+	// Invoice of the drop-in price of the session, or nil when the booking is covered otherwise
+	InvoiceID *uint64 `json:"invoice_id"`
+	// Redemption of the discount code taken off the invoice, or nil when no code was redeemed
+	RedemptionID *uint64 `json:"redemption_id"`
+	// Set on creation when the booking is paid online, pending until the payment succeeds
+	Payment *payments.Payment `gorm:"-" json:"payment,omitempty"`
+	// Discount code to redeem when the session is bought on its own
+	Code string `gorm:"-" json:"code,omitempty"`
+	// Set on creation when the discount code was redeemed, telling the discount applied
+	Discount *promotions.Redemption `gorm:"-" json:"discount,omitempty"`
+	// Set when the discount code was valid but left unused, telling why
+	CodeNotice string `gorm:"-" json:"code_notice,omitempty"`
+	// Set when cancelling, telling how the cancellation policy applied
+	Cancellation *Cancellation `gorm:"-" json:"cancellation,omitempty"`
+	// Set on read when the session on the booking date is cancelled or moved elsewhere
+	SessionCancelled bool `gorm:"-" json:"session_cancelled"`
+}
+
+// BeforeCreate confirm new bookings unless created with another status
+func (b *Booking) BeforeCreate() error {
+	if b.Status == "" {
+		now := time.Now().UTC()
+		b.Status = StatusConfirmed
+		b.ConfirmedAt = &now
+	}
+	return nil
+}
+
+// Active tells if the booking still holds its spot in the session
+func (b *Booking) Active() bool {
+	return b.Status != StatusCancelled
+}
+
+// Transition move booking to status, stamping the time of the change
+func (b *Booking) Transition(status string, at time.Time) error {
+	allowed := false
+	for _, next := range transitions[b.Status] {
+		if next == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return &TransitionError{From: b.Status, To: status}
+	}
+
+	b.Status = status
+	switch status {
+	case StatusConfirmed:
+		b.ConfirmedAt = &at
+	case StatusCancelled:
+		b.CancelledAt = &at
+	case StatusAttended:
+		b.AttendedAt = &at
+	case StatusNoShow:
+		b.NoShowAt = &at
+	}
+	return nil
 }
 
 // MarshalJSON to date correctly
@@ -26,12 +127,28 @@ func (b *Booking) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// UnmarshalJSON to date correctly and strip ID field from requests
+// UnmarshalJSON to date correctly and strip ID and read-only fields from requests
 func (b *Booking) UnmarshalJSON(data []byte) error {
 	type Alias Booking
 	aux := &struct {
-		ID          uint64 `gorm:"-" sql:"-" json:"id"`
-		BookingDate string `json:"booking_date"`
+		ID               uint64                 `gorm:"-" sql:"-" json:"id"`
+		BookingDate      string                 `json:"booking_date"`
+		SessionCancelled bool                   `json:"session_cancelled"`
+		Status           string                 `json:"status"`
+		CreatedAt        time.Time              `json:"created_at"`
+		ConfirmedAt      *time.Time             `json:"confirmed_at"`
+		CancelledAt      *time.Time             `json:"cancelled_at"`
+		AttendedAt       *time.Time             `json:"attended_at"`
+		NoShowAt         *time.Time             `json:"no_show_at"`
+		LateCancelled    bool                   `json:"late_cancelled"`
+		Cancellation     *Cancellation          `json:"cancellation"`
+		PassID           *uint64                `json:"pass_id"`
+		MembershipID     *uint64                `json:"membership_id"`
+		InvoiceID        *uint64                `json:"invoice_id"`
+		RedemptionID     *uint64                `json:"redemption_id"`
+		Payment          *payments.Payment      `json:"payment"`
+		Discount         *promotions.Redemption `json:"discount"`
+		CodeNotice       string                 `json:"code_notice"`
 		*Alias
 	}{
 		Alias: (*Alias)(b),
@@ -53,3 +170,73 @@ func (b *Booking) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// Enrollment request to book every session of a class from a date to the end of the class
+type Enrollment struct {
+	MemberID uint64           `json:"member_id"`
+	From     time.Time        `json:"from"`
+	Weekdays classes.Weekdays `json:"weekdays"`
+	// Book none of the sessions unless all of them can be booked
+	AllOrNothing bool `json:"all_or_nothing"`
+}
+
+// UnmarshalJSON to date correctly
+func (e *Enrollment) UnmarshalJSON(data []byte) error {
+	type Alias Enrollment
+	aux := &struct {
+		From string `json:"from"`
+		*Alias
+	}{
+		Alias: (*Alias)(e),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.From == "" {
+		return nil
+	}
+
+	if len(aux.From) < 10 {
+		return errors.New("Invalid from in payload")
+	}
+
+	e.From, err = time.Parse("2006-01-02", aux.From[0:10])
+	return err
+}
+
+// FailedSession session of an enrollment which couldn't be booked
+type FailedSession struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+// EnrollmentResult bookings made by an enrollment and the sessions it couldn't book
+type EnrollmentResult struct {
+	Booked []Booking       `json:"booked"`
+	Failed []FailedSession `json:"failed"`
+}
+
+// RosterEntry booking of a session with the name of the booked member
+type RosterEntry struct {
+	BookingID  uint64     `json:"booking_id"`
+	MemberID   uint64     `json:"member_id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	AttendedAt *time.Time `json:"attended_at"`
+	NoShowAt   *time.Time `json:"no_show_at"`
+}
+
+// AttendanceMark attendance of a single booking when marking a roster
+type AttendanceMark struct {
+	BookingID uint64 `json:"booking_id"`
+	Attended  bool   `json:"attended"`
+}
+
+// FailedMark attendance mark which couldn't be applied
+type FailedMark struct {
+	BookingID uint64 `json:"booking_id"`
+	Reason    string `json:"reason"`
+}
@@ -0,0 +1,80 @@
+package bookings
+
+import (
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/members"
+	"github.com/teeaa/studio/internal/notify"
+	"github.com/teeaa/studio/internal/webhooks"
+)
+
+// Notify member of the booking about event, unless they have no email address. Failures are
+// only logged, as the booking itself has already been saved.
+func notifyBooking(event string, booking *Booking) {
+	if !notify.Enabled() {
+		return
+	}
+
+	member, err := members.GetMemberByID(booking.MemberID)
+	if err != nil {
+		log.Errorf("Error fetching member %d to notify about booking %d: %s", booking.MemberID, booking.ID, err)
+		return
+	}
+	if member.Email == nil {
+		return
+	}
+
+	class, err := classes.GetClassByID(booking.ClassID)
+	if err != nil {
+		log.Errorf("Error fetching class %d to notify about booking %d: %s", booking.ClassID, booking.ID, err)
+		return
+	}
+
+	err = notify.Send(event, *member.Email, notify.BookingNotice{
+		MemberName:    member.Name,
+		ClassName:     class.Name,
+		BookingID:     booking.ID,
+		Date:          booking.BookingDate.Format("2006-01-02"),
+		StartTime:     class.StartTime,
+		Status:        booking.Status,
+		LateCancelled: booking.LateCancelled,
+	})
+	if err != nil {
+		log.Errorf("Error notifying member %d about booking %d: %s", member.ID, booking.ID, err)
+	}
+}
+
+// NotifyCreated notify member of booking made for them elsewhere, such as from the waitlist,
+// once the booking has been committed
+func NotifyCreated(booking *Booking) {
+	notifyBooking(notify.EventBookingCreated, booking)
+}
+
+// Booking as sent to webhook subscribers, without the client secret of its payment which is
+// only for the member completing the payment
+func webhookPayload(booking *Booking) *Booking {
+	payload := *booking
+	if booking.Payment != nil {
+		payment := *booking.Payment
+		payment.ClientSecret = ""
+		payload.Payment = &payment
+	}
+	return &payload
+}
+
+// Emit webhook event about booking
+func emitBooking(event string, booking *Booking) {
+	webhooks.Emit(event, webhookPayload(booking))
+}
+
+// Emit webhook event about booking inside transaction tx
+func emitBookingTx(tx *gorm.DB, event string, booking *Booking) error {
+	return webhooks.EmitTx(tx, event, webhookPayload(booking))
+}
+
+// EmitCreatedTx emit booking.created webhook event about booking made for the member elsewhere,
+// such as from the waitlist, inside transaction tx so that it's only sent once committed
+func EmitCreatedTx(tx *gorm.DB, booking *Booking) error {
+	return emitBookingTx(tx, webhooks.EventBookingCreated, booking)
+}
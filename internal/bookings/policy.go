@@ -0,0 +1,50 @@
+package bookings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/teeaa/studio/internal/classes"
+)
+
+// Hours before the session bookings can be cancelled free of charge, unless the class sets its own
+var studioCancellationHours uint
+
+// SetCancellationHours set the studio cancellation policy used for classes which don't define their own
+func SetCancellationHours(hours uint) {
+	studioCancellationHours = hours
+}
+
+// Cancellation how the cancellation policy applied when a booking was cancelled
+type Cancellation struct {
+	Late     bool      `json:"late"`
+	Deadline time.Time `json:"deadline"`
+	Rule     string    `json:"rule"`
+}
+
+// Apply the cancellation policy of class to cancelling booking at time now. Late cancellations
+// are past the deadline, and the booking still counts as used.
+func cancellationPolicy(class classes.Class, booking Booking, now time.Time) Cancellation {
+	hours, source := studioCancellationHours, "studio policy"
+	if class.CancellationHours != nil {
+		hours, source = *class.CancellationHours, "class policy"
+	}
+
+	// Sessions without a start time start at local midnight
+	start := class.SessionOn(booking.BookingDate).Start
+	if start.IsZero() {
+		date := booking.BookingDate
+		start = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, class.Location())
+	}
+
+	cancellation := Cancellation{Deadline: start.Add(-time.Duration(hours) * time.Hour)}
+	if hours == 0 {
+		cancellation.Rule = fmt.Sprintf("Free cancellation until the session starts (%s)", source)
+	} else {
+		cancellation.Rule = fmt.Sprintf("Free cancellation up to %d hours before the session (%s)", hours, source)
+	}
+
+	// Pending bookings haven't been confirmed to the member yet, so they're always free to cancel
+	cancellation.Late = booking.Status != StatusPending && now.After(cancellation.Deadline)
+	return cancellation
+}
@@ -0,0 +1,41 @@
+package bookings
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teeaa/studio/internal/classes"
+)
+
+func TestCancellationPolicy(t *testing.T) {
+	defer SetCancellationHours(0)
+	SetCancellationHours(12)
+
+	class := classes.Class{StartTime: "18:00", DurationMinutes: 60, Timezone: "Europe/Helsinki"}
+	booking := Booking{Status: StatusConfirmed, BookingDate: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC)}
+
+	// The session starts at 15:00 UTC, so the studio deadline is 03:00 UTC
+	early := cancellationPolicy(class, booking, time.Date(2019, 8, 15, 2, 59, 0, 0, time.UTC))
+	if early.Late || !early.Deadline.Equal(time.Date(2019, 8, 15, 3, 0, 0, 0, time.UTC)) || !strings.Contains(early.Rule, "12 hours") || !strings.Contains(early.Rule, "studio policy") {
+		t.Error("Expected cancellation before the studio deadline to be free:", early)
+	}
+
+	late := cancellationPolicy(class, booking, time.Date(2019, 8, 15, 3, 1, 0, 0, time.UTC))
+	if !late.Late {
+		t.Error("Expected cancellation after the studio deadline to be late:", late)
+	}
+
+	hours := uint(2)
+	class.CancellationHours = &hours
+	own := cancellationPolicy(class, booking, time.Date(2019, 8, 15, 12, 0, 0, 0, time.UTC))
+	if own.Late || !strings.Contains(own.Rule, "class policy") {
+		t.Error("Expected the class policy to override the studio policy:", own)
+	}
+
+	booking.Status = StatusPending
+	pending := cancellationPolicy(class, booking, time.Date(2019, 8, 15, 16, 0, 0, 0, time.UTC))
+	if pending.Late {
+		t.Error("Expected pending bookings to be free to cancel:", pending)
+	}
+}
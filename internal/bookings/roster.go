@@ -0,0 +1,121 @@
+package bookings
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+// Get class and session date of request and handle error situations
+func getSessionFromReq(w http.ResponseWriter, r *http.Request) (*classes.Class, time.Time, error) {
+	class, err := classes.GetClassFromReq(w, r)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	date, err := time.Parse("2006-01-02", mux.Vars(r)["date"])
+	if err != nil {
+		log.Warn("Invalid session date in request: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid session date, expected YYYY-MM-DD")
+		return nil, time.Time{}, err
+	}
+
+	return class, date, nil
+}
+
+// Roster of the bookings of a class session with the names of the booked members
+func (db *Database) getRoster(classID uint64, date time.Time) ([]RosterEntry, error) {
+	bookings, err := db.getSessionBookings(classID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs := make([]uint64, len(bookings))
+	for i, booking := range bookings {
+		memberIDs[i] = booking.MemberID
+	}
+	bookedMembers, err := members.GetMembersByIDs(memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint64]string{}
+	for _, member := range bookedMembers {
+		names[member.ID] = member.Name
+	}
+
+	roster := make([]RosterEntry, len(bookings))
+	for i, booking := range bookings {
+		roster[i] = RosterEntry{
+			BookingID:  booking.ID,
+			MemberID:   booking.MemberID,
+			Name:       names[booking.MemberID],
+			Status:     booking.Status,
+			AttendedAt: booking.AttendedAt,
+			NoShowAt:   booking.NoShowAt,
+		}
+	}
+
+	return roster, nil
+}
+
+func getRoster(w http.ResponseWriter, r *http.Request) {
+	class, date, err := getSessionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	roster, err := db.getRoster(class.ID, date)
+	if err != nil {
+		log.Error("Error fetching session roster from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&roster)
+}
+
+func markRoster(w http.ResponseWriter, r *http.Request) {
+	class, date, err := getSessionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var marks []AttendanceMark
+	err = json.NewDecoder(r.Body).Decode(&marks)
+	if err != nil || len(marks) == 0 {
+		log.Warn("Error parsing JSON when marking attendance: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for attendance, expected a list of booking_id and attended")
+		return
+	}
+
+	failed, err := db.markAttendance(class.ID, date, marks)
+	if err != nil {
+		log.Error("Error marking attendance in db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	if len(failed) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Attendance wasn't marked as some of the bookings can't be marked",
+			"failed":  failed,
+		})
+		return
+	}
+
+	roster, err := db.getRoster(class.ID, date)
+	if err != nil {
+		log.Error("Error fetching session roster from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&roster)
+}
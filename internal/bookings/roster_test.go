@@ -0,0 +1,120 @@
+package bookings
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/classes"
+)
+
+// Mock bookings 1 and 2 of the class 1 session on 2019-08-15 with their statuses
+func setRosterMatch(firstStatus string, secondStatus string) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	classes.SetupExternally(classes.Database(db))
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (class_id = 1 AND booking_date = 2019-08-15 00:00:00 +0000 UTC AND status <> cancelled)`).WithReply([]map[string]interface{}{
+		{"id": 1, "member_id": 5, "class_id": 1, "booking_date": time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC), "status": firstStatus},
+		{"id": 2, "member_id": 6, "class_id": 1, "booking_date": time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC), "status": secondStatus},
+	})
+	for id, status := range map[int]string{1: firstStatus, 2: secondStatus} {
+		mocket.Catcher.NewMock().WithQuery(`AND ("bookings"."id" = ` + strconv.Itoa(id) + `)`).WithReply([]map[string]interface{}{
+			{"id": id, "class_id": 1, "booking_date": time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC), "status": status},
+		})
+	}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"  WHERE (id IN (5,6))`).WithReply([]map[string]interface{}{
+		{"id": 5, "name": "Another Tester"},
+		{"id": 6, "name": "Patient Tester"},
+	})
+}
+
+func TestGetRoster(t *testing.T) {
+	setRosterMatch(StatusConfirmed, StatusAttended)
+
+	w, r := makeRosterRequest(nil)
+	getRoster(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var roster []RosterEntry
+	err = json.Unmarshal(body, &roster)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if len(roster) != 2 || roster[0].Name != "Another Tester" || roster[0].Status != StatusConfirmed ||
+		roster[1].Name != "Patient Tester" || roster[1].Status != StatusAttended {
+		t.Error("Received roster didn't match expectations:", roster)
+	}
+}
+
+func TestMarkRoster(t *testing.T) {
+	setRosterMatch(StatusConfirmed, StatusConfirmed)
+	var updates []interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "bookings"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		updates = append(updates, args[len(args)-1].Value)
+	})
+
+	w, r := makeRosterRequest([]AttendanceMark{{BookingID: 1, Attended: true}, {BookingID: 2, Attended: false}})
+	markRoster(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+	if len(updates) != 2 {
+		t.Error("Expected both bookings to be marked, updated:", updates)
+	}
+}
+
+func TestMarkRosterCancelledBooking(t *testing.T) {
+	setRosterMatch(StatusConfirmed, StatusCancelled)
+	w, r := makeRosterRequest([]AttendanceMark{{BookingID: 1, Attended: true}, {BookingID: 2, Attended: true}, {BookingID: 3, Attended: true}})
+	markRoster(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+
+	var responseBody struct {
+		Failed []FailedMark `json:"failed"`
+	}
+	err = json.Unmarshal(body, &responseBody)
+	if err != nil {
+		t.Error("Error unmarshalling body:", err)
+	}
+	if len(responseBody.Failed) != 2 || responseBody.Failed[0].BookingID != 2 || responseBody.Failed[1].BookingID != 3 {
+		t.Error("Failed marks didn't match expectations:", responseBody.Failed)
+	}
+}
+
+func makeRosterRequest(requestData []AttendanceMark) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/classes/1/sessions/2019-08-15/roster", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, map[string]string{"id": "1", "date": "2019-08-15"})
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,118 @@
+package calendar
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+// Write iCalendar feed named name with events
+func writeFeed(w http.ResponseWriter, name string, events []Event) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(encode(name, events, time.Now().UTC()))
+}
+
+func getScheduleFeed(w http.ResponseWriter, r *http.Request) {
+	events, err := db.scheduleEvents(time.Now().UTC())
+	if err != nil {
+		log.Error("Error fetching class schedule from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	writeFeed(w, "Dance studio classes", events)
+}
+
+func getMemberFeed(w http.ResponseWriter, r *http.Request) {
+	var token Token
+	err := db.Where("token = ?", mux.Vars(r)["token"]).First(&token).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warn("Requested calendar feed with an unknown token")
+			helpers.ResponseJSON(w, http.StatusNotFound, "Calendar feed does not exist")
+		} else {
+			log.Error("Error fetching calendar token from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return
+	}
+
+	events, err := db.memberEvents(token.MemberID, time.Now().UTC())
+	if err != nil {
+		log.Error("Error fetching bookings of member from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	writeFeed(w, "Dance studio bookings", events)
+}
+
+func getMemberToken(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var token Token
+	err = db.Where("member_id = ?", member.ID).First(&token).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			helpers.ResponseJSON(w, http.StatusNotFound, "Member has no calendar feed")
+		} else {
+			log.Error("Error fetching calendar token from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(&token)
+}
+
+// Create the calendar feed token of the member, or replace it so that the old feed URL stops working
+func rotateMemberToken(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	value, err := generateToken()
+	if err != nil {
+		log.Error("Error generating calendar token: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	token := Token{MemberID: member.ID, Token: value, CreatedAt: time.Now().UTC()}
+	err = db.Save(&token).Error
+	if err != nil {
+		log.Error("Error saving calendar token to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&token)
+}
+
+// MemberRoutes set routes for calendar feed tokens under /members
+func MemberRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/calendar-token", getMemberToken).Methods("GET")
+	router.HandleFunc("/{id}/calendar-token", rotateMemberToken).Methods("POST")
+}
+
+// Routes set routes for /calendar
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Token{})
+
+	router.HandleFunc("/classes.ics", getScheduleFeed).Methods("GET")
+	router.HandleFunc("/members/{token:[0-9a-f]+}.ics", getMemberFeed).Methods("GET")
+}
@@ -0,0 +1,143 @@
+package calendar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() *mux.Router {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+
+	router := mux.NewRouter()
+	Routes(gormDB, router.PathPrefix("/calendar").Subrouter())
+	return router
+}
+
+// Mock Monday and Wednesday class starting at 18:30 UTC from 1 to 10 July
+func setClassMatch() {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"`).WithReply([]map[string]interface{}{{
+		"id":               1,
+		"name":             "Salsa basics",
+		"start_date":       time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":         time.Date(2019, 7, 10, 0, 0, 0, 0, time.UTC),
+		"weekdays":         "MO,WE",
+		"start_time":       "18:30",
+		"duration_minutes": 60,
+		"timezone":         "UTC",
+	}})
+}
+
+func TestLineFolding(t *testing.T) {
+	var w icsWriter
+	summary := strings.Repeat("Salsa, bachata & kizomba ", 3) + strings.Repeat("ä", 20)
+	w.line("SUMMARY", escapeText(summary))
+
+	lines := strings.Split(strings.TrimSuffix(w.buf.String(), "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Fatal("Expected long line to be folded, got:", lines)
+	}
+	for _, line := range lines {
+		if len(line) > 75 {
+			t.Errorf("Expected lines of at most 75 octets, got %d: %q", len(line), line)
+		}
+	}
+
+	unfolded := strings.Replace(strings.TrimSuffix(w.buf.String(), "\r\n"), "\r\n ", "", -1)
+	if unfolded != "SUMMARY:"+strings.Replace(summary, ",", "\\,", -1) {
+		t.Error("Unfolded line didn't match the original:", unfolded)
+	}
+}
+
+func TestScheduleEvents(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	setClassMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_exceptions"`).WithReply([]map[string]interface{}{{
+		"id":       3,
+		"class_id": 1,
+		"date":     time.Date(2019, 7, 3, 0, 0, 0, 0, time.UTC),
+		"moved_to": time.Date(2019, 7, 4, 0, 0, 0, 0, time.UTC),
+	}})
+
+	events, err := db.scheduleEvents(time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal("Error fetching schedule:", err)
+	}
+
+	expected := []string{"class-1-20190701@dancestudio", "class-1-20190703@dancestudio", "class-1-20190708@dancestudio", "class-1-20190710@dancestudio"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %v", len(expected), len(events), events)
+	}
+	for i, uid := range expected {
+		if events[i].UID != uid {
+			t.Errorf("Expected event %d to have UID %s, got %s", i, uid, events[i].UID)
+		}
+	}
+	if !events[1].Start.Equal(time.Date(2019, 7, 4, 18, 30, 0, 0, time.UTC)) {
+		t.Error("Expected moved session to keep its UID and start on its new date, got:", events[1].Start)
+	}
+}
+
+func TestMemberFeed(t *testing.T) {
+	router := setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "calendar_tokens"  WHERE (token = 0a1b2c)`).WithReply([]map[string]interface{}{{"member_id": 5, "token": "0a1b2c"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"`).WithReply([]map[string]interface{}{
+		{"id": 7, "member_id": 5, "class_id": 1, "booking_date": time.Date(2019, 7, 8, 0, 0, 0, 0, time.UTC), "status": "confirmed"},
+		{"id": 8, "member_id": 5, "class_id": 1, "booking_date": time.Date(2019, 7, 10, 0, 0, 0, 0, time.UTC), "status": "cancelled"},
+	})
+	setClassMatch()
+
+	r := httptest.NewRequest("GET", "/calendar/members/0a1b2c.ics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status 200, got %d instead", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+		t.Error("Unexpected content type:", w.Header().Get("Content-Type"))
+	}
+
+	feed := string(body)
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:booking-7@dancestudio\r\nDTSTAMP:",
+		"DTSTART:20190708T183000Z\r\nDTEND:20190708T193000Z\r\n",
+		"UID:booking-8@dancestudio\r\n",
+		"STATUS:CANCELLED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(feed, expected) {
+			t.Errorf("Expected feed to contain %q, got:\n%s", expected, feed)
+		}
+	}
+}
+
+func TestMemberFeedUnknownToken(t *testing.T) {
+	router := setup()
+	mocket.Catcher.Reset()
+
+	r := httptest.NewRequest("GET", "/calendar/members/0a1b2c.ics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
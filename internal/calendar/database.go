@@ -0,0 +1,107 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// Days of past sessions and bookings kept in the feeds, and days of upcoming sessions in the schedule
+const (
+	pastDays     = 30
+	scheduleDays = 365
+)
+
+// Generate a random feed token
+func generateToken() (string, error) {
+	token := make([]byte, 20)
+	_, err := io.ReadFull(rand.Reader, token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// Events of the sessions of all classes from pastDays before now to scheduleDays after it
+func (db *Database) scheduleEvents(now time.Time) ([]Event, error) {
+	from := classes.DateOf(now).AddDate(0, 0, -pastDays)
+	to := classes.DateOf(now).AddDate(0, 0, scheduleDays)
+
+	var all []classes.Class
+	err := db.Where("end_date >= ? AND start_date <= ?", from, to).Order("id ASC").Find(&all).Error
+	if err != nil {
+		return nil, err
+	}
+
+	events := []Event{}
+	for i := range all {
+		calendar, err := classes.GetCalendar(db.DB, all[i].ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, sessionEvents(&all[i], all[i].Sessions(from, to, calendar))...)
+	}
+	return events, nil
+}
+
+// Events of the bookings of member from pastDays before now on. Bookings of classes which
+// have been removed are left out.
+func (db *Database) memberEvents(memberID uint64, now time.Time) ([]Event, error) {
+	from := classes.DateOf(now).AddDate(0, 0, -pastDays)
+
+	var booked []bookings.Booking
+	err := db.Where("member_id = ? AND booking_date >= ?", memberID, from).Order("booking_date ASC").Find(&booked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	events := []Event{}
+	if len(booked) == 0 {
+		return events, nil
+	}
+	to := classes.DateOf(booked[len(booked)-1].BookingDate)
+
+	classesByID := map[uint64]*classes.Class{}
+	calendars := map[uint64]classes.Calendar{}
+	for i := range booked {
+		class, ok := classesByID[booked[i].ClassID]
+		if !ok {
+			var found classes.Class
+			err = db.First(&found, booked[i].ClassID).Error
+			if err != nil && !gorm.IsRecordNotFoundError(err) {
+				return nil, err
+			}
+			if err == nil {
+				class = &found
+				calendars[found.ID], err = classes.GetCalendar(db.DB, found.ID, from, to)
+				if err != nil {
+					return nil, err
+				}
+			}
+			classesByID[booked[i].ClassID] = class
+		}
+		if class == nil {
+			continue
+		}
+
+		events = append(events, bookingEvent(&booked[i], class, calendars[class.ID]))
+	}
+	return events, nil
+}
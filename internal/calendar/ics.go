@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Domain part of event UIDs. UIDs are built from ids and dates only, so that calendar
+// apps update an event when it changes instead of adding it again.
+const uidDomain = "dancestudio"
+
+// Event a single VEVENT of a feed. Timed events are written in UTC, events without a
+// start time as all day events on Date.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time
+	Start       time.Time
+	End         time.Time
+	Cancelled   bool
+}
+
+// Writer of an RFC 5545 iCalendar object, with content lines ending in CRLF and folded
+// to at most 75 octets
+type icsWriter struct {
+	buf bytes.Buffer
+}
+
+// Escape TEXT value
+func escapeText(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, ";", "\\;", -1)
+	value = strings.Replace(value, ",", "\\,", -1)
+	value = strings.Replace(value, "\r\n", "\\n", -1)
+	return strings.Replace(value, "\n", "\\n", -1)
+}
+
+// Write content line, folding it without splitting multi-byte characters
+func (w *icsWriter) line(name string, value string) {
+	content := name + ":" + value
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		w.buf.WriteString(content[:cut])
+		w.buf.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = 74
+	}
+	w.buf.WriteString(content)
+	w.buf.WriteString("\r\n")
+}
+
+// Write VEVENT of event stamped at now
+func (w *icsWriter) event(event Event, now time.Time) {
+	w.line("BEGIN", "VEVENT")
+	w.line("UID", event.UID)
+	w.line("DTSTAMP", now.UTC().Format("20060102T150405Z"))
+	if event.Start.IsZero() {
+		w.line("DTSTART;VALUE=DATE", event.Date.Format("20060102"))
+	} else {
+		w.line("DTSTART", event.Start.UTC().Format("20060102T150405Z"))
+		w.line("DTEND", event.End.UTC().Format("20060102T150405Z"))
+	}
+	w.line("SUMMARY", escapeText(event.Summary))
+	if event.Description != "" {
+		w.line("DESCRIPTION", escapeText(event.Description))
+	}
+	if event.Cancelled {
+		w.line("STATUS", "CANCELLED")
+	} else {
+		w.line("STATUS", "CONFIRMED")
+	}
+	w.line("END", "VEVENT")
+}
+
+// Encode events as an iCalendar object named name, stamped at now
+func encode(name string, events []Event, now time.Time) []byte {
+	var w icsWriter
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//Dance studio//Class schedule//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.line("X-WR-CALNAME", escapeText(name))
+	for _, event := range events {
+		w.event(event, now)
+	}
+	w.line("END", "VCALENDAR")
+	return w.buf.Bytes()
+}
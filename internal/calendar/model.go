@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+)
+
+// Token representation of calendar.calendar_tokens, the secret in the URL of the booking
+// feed of a member. Feeds are read by calendar apps which can't authenticate otherwise,
+// so the token is the only thing protecting the feed and can be rotated.
+type Token struct {
+	MemberID  uint64    `gorm:"primary_key;auto_increment:false" json:"member_id"`
+	Token     string    `gorm:"unique_index" json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName to name tokens after the feed
+func (Token) TableName() string {
+	return "calendar_tokens"
+}
+
+// MarshalJSON to include the path of the feed
+func (t *Token) MarshalJSON() ([]byte, error) {
+	type Alias Token
+	return json.Marshal(&struct {
+		URL string `json:"url"`
+		*Alias
+	}{
+		URL:   "/calendar/members/" + t.Token + ".ics",
+		Alias: (*Alias)(t),
+	})
+}
+
+// Events of the sessions of class. A moved session keeps the UID of its original date,
+// so that calendar apps move the event rather than add another one.
+func sessionEvents(class *classes.Class, sessions []classes.Session) []Event {
+	events := []Event{}
+	for _, session := range sessions {
+		if !session.MovedTo.IsZero() {
+			continue
+		}
+
+		uidDate := session.Date
+		description := session.Reason
+		if !session.MovedFrom.IsZero() {
+			uidDate = session.MovedFrom
+			description = fmt.Sprintf("Moved from %s", session.MovedFrom.Format("2006-01-02"))
+		}
+
+		events = append(events, Event{
+			UID:         fmt.Sprintf("class-%d-%s@%s", class.ID, uidDate.Format("20060102"), uidDomain),
+			Summary:     class.Name,
+			Description: description,
+			Date:        session.Date,
+			Start:       session.Start,
+			End:         session.End,
+			Cancelled:   session.Cancelled,
+		})
+	}
+	return events
+}
+
+// Event of a booking of class. Cancelled bookings and bookings of sessions which aren't held
+// are kept as cancelled events, so that calendar apps remove them.
+func bookingEvent(booking *bookings.Booking, class *classes.Class, calendar classes.Calendar) Event {
+	session := class.SessionOn(booking.BookingDate)
+	event := Event{
+		UID:       fmt.Sprintf("booking-%d@%s", booking.ID, uidDomain),
+		Summary:   class.Name,
+		Date:      session.Date,
+		Start:     session.Start,
+		End:       session.End,
+		Cancelled: booking.Status == bookings.StatusCancelled,
+	}
+	if booking.Status == bookings.StatusPending {
+		event.Description = "Awaiting payment"
+	}
+	if err := class.CheckDate(booking.BookingDate, calendar); err != nil {
+		event.Cancelled = true
+		event.Description = err.Error()
+	}
+	return event
+}
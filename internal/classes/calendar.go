@@ -0,0 +1,142 @@
+package classes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/teeaa/studio/internal/blackouts"
+)
+
+// Calendar of dated changes applied on top of the weekly schedule of a class
+type Calendar struct {
+	Exceptions []Exception
+	Blackouts  []blackouts.Blackout
+}
+
+// Blackout closing date for classes held in room roomID
+func (cal *Calendar) blackoutOn(date time.Time, roomID *uint64) *blackouts.Blackout {
+	for i := range cal.Blackouts {
+		if cal.Blackouts[i].Covers(date, roomID) {
+			return &cal.Blackouts[i]
+		}
+	}
+	return nil
+}
+
+// Exception cancelling or moving away the session on date
+func (cal *Calendar) exceptionOn(date time.Time) *Exception {
+	for i := range cal.Exceptions {
+		if DateOf(cal.Exceptions[i].Date).Equal(date) {
+			return &cal.Exceptions[i]
+		}
+	}
+	return nil
+}
+
+// Exception moving a session onto date
+func (cal *Calendar) exceptionMovedTo(date time.Time) *Exception {
+	for i := range cal.Exceptions {
+		if cal.Exceptions[i].MovedTo != nil && DateOf(*cal.Exceptions[i].MovedTo).Equal(date) {
+			return &cal.Exceptions[i]
+		}
+	}
+	return nil
+}
+
+// Describe why the session on date isn't held as scheduled
+func (e *Exception) describe() string {
+	if e.MovedTo != nil {
+		return fmt.Sprintf("Session on %s is moved to %s", e.Date.Format("2006-01-02"), e.MovedTo.Format("2006-01-02"))
+	}
+	if e.Reason != "" {
+		return fmt.Sprintf("Session on %s is cancelled: %s", e.Date.Format("2006-01-02"), e.Reason)
+	}
+	return fmt.Sprintf("Session on %s is cancelled", e.Date.Format("2006-01-02"))
+}
+
+// CheckDate check the class is held on date, and tell why when it's not
+func (c *Class) CheckDate(date time.Time, calendar Calendar) error {
+	date = DateOf(date)
+	if blackout := calendar.blackoutOn(date, c.RoomID); blackout != nil {
+		return errors.New(blackout.Describe())
+	}
+
+	if calendar.exceptionMovedTo(date) != nil {
+		return nil
+	}
+
+	if exception := calendar.exceptionOn(date); exception != nil {
+		return errors.New(exception.describe())
+	}
+
+	if date.Before(DateOf(c.StartDate)) || date.After(DateOf(c.EndDate)) {
+		return errors.New("Booked date outside class start and end")
+	}
+
+	if !c.Weekdays.Has(date.Weekday()) {
+		return errors.New("Class is not held on the booked weekday")
+	}
+
+	return nil
+}
+
+// Sessions list sessions of the class between dates from and to. Cancelled sessions and
+// sessions falling on blackouts are listed with the reason, and moved sessions both on
+// their original and their new date.
+func (c *Class) Sessions(from time.Time, to time.Time, calendar Calendar) []Session {
+	from, to = DateOf(from), DateOf(to)
+
+	sessions := []Session{}
+	first, last := from, to
+	if first.Before(DateOf(c.StartDate)) {
+		first = DateOf(c.StartDate)
+	}
+	if last.After(DateOf(c.EndDate)) {
+		last = DateOf(c.EndDate)
+	}
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !c.Weekdays.Has(day.Weekday()) {
+			continue
+		}
+
+		session := c.SessionOn(day)
+		if exception := calendar.exceptionOn(day); exception != nil {
+			session.Cancelled = true
+			session.Reason = exception.Reason
+			if exception.MovedTo != nil {
+				session.MovedTo = DateOf(*exception.MovedTo)
+			}
+		}
+		sessions = append(sessions, session)
+	}
+
+	for _, exception := range calendar.Exceptions {
+		if exception.MovedTo == nil {
+			continue
+		}
+
+		day := DateOf(*exception.MovedTo)
+		if day.Before(from) || day.After(to) {
+			continue
+		}
+
+		session := c.SessionOn(day)
+		session.MovedFrom = DateOf(exception.Date)
+		sessions = append(sessions, session)
+	}
+
+	for i := range sessions {
+		if blackout := calendar.blackoutOn(sessions[i].Date, c.RoomID); blackout != nil {
+			sessions[i].Cancelled = true
+			sessions[i].Reason = blackout.Describe()
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Date.Before(sessions[j].Date)
+	})
+
+	return sessions
+}
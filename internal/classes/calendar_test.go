@@ -0,0 +1,129 @@
+package classes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teeaa/studio/internal/blackouts"
+)
+
+func testCalendarClass() Class {
+	return Class{
+		ID:        1,
+		StartDate: time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Weekdays:  NewWeekdays(time.Tuesday),
+	}
+}
+
+func TestCheckDateWithExceptions(t *testing.T) {
+	class := testCalendarClass()
+	movedTo := time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC)
+	calendar := Calendar{Exceptions: []Exception{
+		{ClassID: 1, Date: time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC), Reason: "Teacher sick"},
+		{ClassID: 1, Date: time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), MovedTo: &movedTo},
+	}}
+
+	err := class.CheckDate(time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC), calendar)
+	if err == nil || err.Error() != "Session on 2019-08-13 is cancelled: Teacher sick" {
+		t.Error("Expected cancelled session to be rejected with its reason, got:", err)
+	}
+
+	err = class.CheckDate(time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), calendar)
+	if err == nil || err.Error() != "Session on 2019-08-20 is moved to 2019-08-22" {
+		t.Error("Expected moved session to be rejected on its original date, got:", err)
+	}
+
+	err = class.CheckDate(movedTo, calendar)
+	if err != nil {
+		t.Error("Expected moved session to be held on its new Thursday date, got:", err)
+	}
+
+	err = class.CheckDate(time.Date(2019, 8, 27, 0, 0, 0, 0, time.UTC), calendar)
+	if err != nil {
+		t.Error("Expected regular session to be held, got:", err)
+	}
+}
+
+func TestSessionsWithExceptions(t *testing.T) {
+	class := testCalendarClass()
+	movedTo := time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC)
+	calendar := Calendar{Exceptions: []Exception{
+		{ClassID: 1, Date: time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), MovedTo: &movedTo, Reason: "Public holiday"},
+	}}
+
+	sessions := class.Sessions(time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC), time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC), calendar)
+	if len(sessions) != 3 {
+		t.Fatal("Expected original, moved and regular sessions, got:", sessions)
+	}
+
+	if !sessions[0].Cancelled || !sessions[0].MovedTo.Equal(movedTo) || sessions[0].Reason != "Public holiday" {
+		t.Error("Expected original session to be cancelled and point to the new date:", sessions[0])
+	}
+	if sessions[1].Cancelled || !sessions[1].Date.Equal(movedTo) || !sessions[1].MovedFrom.Equal(time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected moved session on the new date:", sessions[1])
+	}
+	if sessions[2].Cancelled || !sessions[2].Date.Equal(time.Date(2019, 8, 27, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected regular session after the moved one:", sessions[2])
+	}
+}
+
+func TestBlackoutInCalendar(t *testing.T) {
+	class := testCalendarClass()
+	movedTo := time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC)
+	calendar := Calendar{
+		Exceptions: []Exception{{ClassID: 1, Date: time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC), MovedTo: &movedTo}},
+		Blackouts: []blackouts.Blackout{{
+			ID:        2,
+			Name:      "Summer break",
+			StartDate: time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2019, 8, 25, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	err := class.CheckDate(time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), calendar)
+	if err == nil || err.Error() != "Studio is closed for Summer break (blackout 2)" {
+		t.Error("Expected regular session to be rejected naming the blackout, got:", err)
+	}
+
+	err = class.CheckDate(movedTo, calendar)
+	if err == nil {
+		t.Error("Expected session moved onto a blackout to be rejected")
+	}
+
+	sessions := class.Sessions(time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC), time.Date(2019, 8, 23, 0, 0, 0, 0, time.UTC), calendar)
+	if len(sessions) != 2 {
+		t.Fatal("Expected blacked out and moved sessions, got:", sessions)
+	}
+	for _, session := range sessions {
+		if !session.Cancelled || session.Reason != "Studio is closed for Summer break (blackout 2)" {
+			t.Error("Expected session to be listed as closed by the blackout:", session)
+		}
+	}
+}
+
+func TestRoomBlackoutInCalendar(t *testing.T) {
+	class := testCalendarClass()
+	roomID, otherRoomID := uint64(1), uint64(2)
+	calendar := Calendar{
+		Blackouts: []blackouts.Blackout{{
+			ID:        3,
+			Name:      "Floor renovation",
+			StartDate: time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC),
+			RoomID:    &roomID,
+		}},
+	}
+
+	class.RoomID = &otherRoomID
+	err := class.CheckDate(time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), calendar)
+	if err != nil {
+		t.Error("Expected blackout of another room not to apply, got:", err)
+	}
+
+	class.RoomID = &roomID
+	err = class.CheckDate(time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC), calendar)
+	if err == nil || err.Error() != "Room is closed for Floor renovation (blackout 3)" {
+		t.Error("Expected session in the closed room to be rejected, got:", err)
+	}
+}
@@ -2,17 +2,21 @@ package classes
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	log "github.com/sirupsen/logrus"
 	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/instructors"
+	"github.com/teeaa/studio/internal/webhooks"
 )
 
 func getClasses(w http.ResponseWriter, r *http.Request) {
 	var classes []Class
-	err := db.Find(&classes).Error
+	err := filterClasses(db.DB, r.URL.Query()).Find(&classes).Error
 
 	if err != nil {
 		log.Error("Error fetching classes from db: ", err)
@@ -20,9 +24,25 @@ func getClasses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = loadDetails(db.DB, classes)
+	if err != nil {
+		log.Error("Error fetching details of classes from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
 	json.NewEncoder(w).Encode(&classes)
 }
 
+// Respond to instructors or the room of a class being taken by other classes at the same time
+func respondConflicts(w http.ResponseWriter, conflicts []Conflict) {
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   describeConflicts(conflicts),
+		"conflicts": conflicts,
+	})
+}
+
 func addClass(w http.ResponseWriter, r *http.Request) {
 	var class Class
 	err := json.NewDecoder(r.Body).Decode(&class)
@@ -32,12 +52,22 @@ func addClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.Create(&class).Error
+	conflicts, err := db.saveClass(&class)
+	if err == ErrNoSuchInstructor || err == ErrNoSuchRoom || err == ErrNoSuchLevel {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(conflicts) > 0 {
+		respondConflicts(w, conflicts)
+		return
+	}
 	if err != nil {
 		log.Error("Error inserting class to db: ", err)
 		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
+	webhooks.Emit(webhooks.EventClassCreated, &class)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(&class)
 }
@@ -56,6 +86,7 @@ func updateClass(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	previous := *class
 
 	err = json.NewDecoder(r.Body).Decode(&class)
 	if err != nil {
@@ -64,15 +95,29 @@ func updateClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = db.Save(&class).Error
+	conflicts, err := db.saveClass(class)
+	if err == ErrNoSuchInstructor || err == ErrNoSuchRoom || err == ErrNoSuchLevel {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(conflicts) > 0 {
+		respondConflicts(w, conflicts)
+		return
+	}
 	if err != nil {
 		log.Error("Error saving class to db: ", err)
 		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
+	webhooks.Emit(webhooks.EventClassUpdated, class)
+	if scheduleChanged(previous, *class) {
+		db.notifyClassChanged(class, fmt.Sprintf("The schedule has changed: sessions are held from %s to %s at %s for %d minutes.",
+			class.StartDate.Format("2006-01-02"), class.EndDate.Format("2006-01-02"), class.StartTime, class.DurationMinutes))
+	}
 
 	json.NewEncoder(w).Encode(&class)
 }
+
 func deleteClass(w http.ResponseWriter, r *http.Request) {
 	class, err := db.getClassFromReq(w, r)
 	if err != nil {
@@ -80,22 +125,167 @@ func deleteClass(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = db.Delete(&class).Error
+	if err == nil {
+		err = db.Where("class_id = ?", class.ID).Delete(&ClassInstructor{}).Error
+	}
+	if err == nil {
+		err = db.Where("class_id = ?", class.ID).Delete(&ClassTag{}).Error
+	}
+	if err == nil {
+		err = db.Where("class_id = ?", class.ID).Delete(&SessionPrice{}).Error
+	}
 	if err != nil {
 		log.Error("Error deleting class from db: ", err)
 		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
+	db.notifyClassChanged(class, "The class has been discontinued and its sessions will not be held.")
+	webhooks.Emit(webhooks.EventClassDeleted, class)
 
 	helpers.ResponseJSON(w, 200, "Class removed")
 }
 
+// Parse optional date query parameter, falling back to def when it's not given
+func parseDateParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
+
+// Get from and to dates of request, defaulting to class start and end dates, and handle error situations
+func getDateRangeFromReq(w http.ResponseWriter, r *http.Request, class *Class) (time.Time, time.Time, error) {
+	from, err := parseDateParam(r, "from", class.StartDate)
+	if err != nil {
+		log.Warn("Invalid from date in request: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid from date, expected YYYY-MM-DD")
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseDateParam(r, "to", class.EndDate)
+	if err != nil {
+		log.Warn("Invalid to date in request: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid to date, expected YYYY-MM-DD")
+		return time.Time{}, time.Time{}, err
+	}
+
+	return from, to, nil
+}
+
+func getSessions(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	from, to, err := getDateRangeFromReq(w, r, class)
+	if err != nil {
+		return
+	}
+
+	calendar, err := GetCalendar(db.DB, class.ID, from, to)
+	if err != nil {
+		log.Error("Error fetching class calendar from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	sessions := class.Sessions(from, to, calendar)
+	json.NewEncoder(w).Encode(&sessions)
+}
+
+func getAvailability(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	from, to, err := getDateRangeFromReq(w, r, class)
+	if err != nil {
+		return
+	}
+
+	calendar, err := GetCalendar(db.DB, class.ID, from, to)
+	if err != nil {
+		log.Error("Error fetching class calendar from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	booked, err := db.countBookingsByDate(class.ID, from, to)
+	if err != nil {
+		log.Error("Error counting bookings from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	capacity := class.EffectiveCapacity()
+	availability := []Availability{}
+	for _, session := range class.Sessions(from, to, calendar) {
+		if session.Cancelled {
+			continue
+		}
+
+		date := session.Date.Format("2006-01-02")
+		remaining := uint(0)
+		if booked[date] < capacity {
+			remaining = capacity - booked[date]
+		}
+		availability = append(availability, Availability{date, capacity, booked[date], remaining})
+	}
+
+	json.NewEncoder(w).Encode(&availability)
+}
+
+func getInstructorClasses(w http.ResponseWriter, r *http.Request) {
+	instructor, err := instructors.GetInstructorFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var classes []Class
+	err = db.Joins("JOIN class_instructors ON class_instructors.class_id = classes.id").
+		Where("class_instructors.instructor_id = ?", instructor.ID).
+		Order("classes.start_date ASC").
+		Find(&classes).Error
+	if err == nil {
+		err = loadDetails(db.DB, classes)
+	}
+	if err != nil {
+		log.Error("Error fetching classes of instructor from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&classes)
+}
+
+// InstructorRoutes set routes for classes under /instructors
+func InstructorRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/classes", getInstructorClasses).Methods("GET")
+}
+
 // Routes set routes for /classes
 func Routes(gormDB *gorm.DB, router *mux.Router) {
 	db = Database{gormDB}
-	db.AutoMigrate(&Class{})
+	db.AutoMigrate(&Class{}, &Exception{}, &ClassInstructor{}, &Style{}, &Level{}, &Tag{}, &ClassTag{}, &SessionPrice{})
 	router.HandleFunc("", getClasses).Methods("GET")
 	router.HandleFunc("", addClass).Methods("POST")
+	router.HandleFunc("/facets", getFacets).Methods("GET")
 	router.HandleFunc("/{id}", getClass).Methods("GET")
 	router.HandleFunc("/{id}", updateClass).Methods("PUT")
 	router.HandleFunc("/{id}", deleteClass).Methods("DELETE")
+	router.HandleFunc("/{id}/sessions", getSessions).Methods("GET")
+	router.HandleFunc("/{id}/availability", getAvailability).Methods("GET")
+	router.HandleFunc("/{id}/exceptions", getExceptions).Methods("GET")
+	router.HandleFunc("/{id}/exceptions", addException).Methods("POST")
+	router.HandleFunc("/{id}/exceptions/{exceptionID}", getException).Methods("GET")
+	router.HandleFunc("/{id}/exceptions/{exceptionID}", updateException).Methods("PUT")
+	router.HandleFunc("/{id}/exceptions/{exceptionID}", deleteException).Methods("DELETE")
+	router.HandleFunc("/{id}/prices", getSessionPrices).Methods("GET")
+	router.HandleFunc("/{id}/sessions/{date}/price", setSessionPrice).Methods("PUT")
+	router.HandleFunc("/{id}/sessions/{date}/price", deleteSessionPrice).Methods("DELETE")
 }
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -53,11 +54,11 @@ func TestAddClass(t *testing.T) {
 	mocket.Catcher.Reset().NewMock().WithQuery(`INSERT INTO "classes"`)
 
 	requestData := Class{
-		123, // Sent ID shouldn't affect result
-		"Class #1",
-		time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
-		20,
+		ID:        123, // Sent ID shouldn't affect result
+		Name:      "Class #1",
+		StartDate: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  20,
 	}
 
 	w, r, _ := makeRequest(&requestData, nil)
@@ -79,17 +80,38 @@ func TestAddClass(t *testing.T) {
 	}
 
 	compare := Class{
-		0,
-		"Class #1",
-		time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
-		20,
-	}
-	if compare != class {
+		ID:            0,
+		Name:          "Class #1",
+		StartDate:     time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:      20,
+		InstructorIDs: []uint64{},
+		Tags:          []string{},
+		Timezone:      "UTC", // Defaulted to studio timezone when unmarshalling
+	}
+	if !reflect.DeepEqual(compare, class) {
 		t.Error("Received class data didn't match expectations:", compare, class)
 	}
 }
 
+func TestAddClassInvalidTimezone(t *testing.T) {
+	requestData := Class{
+		Name:      "Class #1",
+		StartDate: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  20,
+		StartTime: "18:30",
+		Timezone:  "Europe/Nowhere",
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
 func TestGetClassesData(t *testing.T) {
 	commonReply := []map[string]interface{}{{
 		"id":         1,
@@ -113,11 +135,13 @@ func TestGetClassesData(t *testing.T) {
 	}
 
 	compare, _ := json.Marshal([]Class{{
-		1,
-		"Class #1",
-		time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
-		20,
+		ID:            1,
+		Name:          "Class #1",
+		StartDate:     time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:      20,
+		InstructorIDs: []uint64{},
+		Tags:          []string{},
 	}})
 
 	// Need to compare response without Unmarshal because that would reset ids
@@ -140,11 +164,11 @@ func TestPutClass(t *testing.T) {
 	mocket.Catcher.NewMock().WithQuery(`UPDATE "classes" SET "name" = ?, "start_date" = ?, "end_date" = ?, "capacity" = ?  WHERE "classes"."id" = ?`)
 
 	requestData := Class{
-		123, // Sent ID shouldn't affect result
-		"New class name",
-		time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
-		15,
+		ID:        123, // Sent ID shouldn't affect result
+		Name:      "New class name",
+		StartDate: time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
+		Capacity:  15,
 	}
 
 	commonReply[0]["name"] = requestData.Name
@@ -166,11 +190,14 @@ func TestPutClass(t *testing.T) {
 	}
 
 	compare := Class{
-		1,
-		"New class name",
-		time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
-		15,
+		ID:            1,
+		Name:          "New class name",
+		StartDate:     time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
+		Capacity:      15,
+		InstructorIDs: []uint64{},
+		Tags:          []string{},
+		Timezone:      "UTC", // Defaulted to studio timezone when unmarshalling
 	}
 
 	var responseClass Class
@@ -178,7 +205,7 @@ func TestPutClass(t *testing.T) {
 	responseClass.ID = 1
 
 	// Compare with hard set ID because keys in json structure might change places
-	if compare != responseClass {
+	if !reflect.DeepEqual(compare, responseClass) {
 		t.Error("Received data didn't match expectations:", compare, responseClass)
 	}
 }
@@ -224,11 +251,14 @@ func TestGetClass(t *testing.T) {
 	}
 
 	compare := Class{
-		0,
-		"New class name",
-		time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
-		15,
+		ID:            0,
+		Name:          "New class name",
+		StartDate:     time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
+		Capacity:      15,
+		InstructorIDs: []uint64{},
+		Tags:          []string{},
+		Timezone:      "UTC", // Defaulted to studio timezone when unmarshalling
 	}
 
 	var responseBody Class
@@ -237,7 +267,7 @@ func TestGetClass(t *testing.T) {
 		t.Error("Error unmarshalling body:", err)
 	}
 
-	if compare != responseBody {
+	if !reflect.DeepEqual(compare, responseBody) {
 		t.Error("Response body didn't match expectations:", compare, responseBody)
 	}
 }
@@ -254,11 +284,11 @@ func TestGetClassNonExisting(t *testing.T) {
 
 func TestPutClassNonExisting(t *testing.T) {
 	requestData := Class{
-		234,
-		"Shouldn't work",
-		time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
-		15,
+		ID:        234,
+		Name:      "Shouldn't work",
+		StartDate: time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 22, 0, 0, 0, 0, time.UTC),
+		Capacity:  15,
 	}
 	w, r, _ := makeRequest(&requestData, map[string]string{"id": "234"})
 
@@ -279,6 +309,125 @@ func TestDeleteClassNonExisting(t *testing.T) {
 	}
 }
 
+func TestGetAvailability(t *testing.T) {
+	commonReply := []map[string]interface{}{{
+		"id":         1,
+		"name":       "Class #1",
+		"start_date": time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+	}}
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply(commonReply)
+	bookedReply := []map[string]interface{}{
+		{"booking_date": time.Date(2019, 8, 30, 0, 0, 0, 0, time.UTC), "booked": 3},
+		{"booking_date": time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC), "booked": 20},
+	}
+	mocket.Catcher.NewMock().WithQuery(`SELECT booking_date, count(*) AS booked FROM "bookings"  WHERE (class_id = 1 AND booking_date BETWEEN 2019-08-29`).WithReply(bookedReply)
+
+	w, r, _ := makeRequest(nil, map[string]string{"id": "1"})
+	r.URL.RawQuery = "from=2019-08-29&to=2019-09-05"
+	getAvailability(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var availability []Availability
+	err = json.Unmarshal(body, &availability)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	// Range is cut at class end date
+	compare := []Availability{
+		{"2019-08-29", 20, 0, 20},
+		{"2019-08-30", 20, 3, 17},
+		{"2019-08-31", 20, 20, 0},
+	}
+	if len(compare) != len(availability) {
+		t.Fatal("Received availability didn't match expectations:", compare, availability)
+	}
+	for i := range compare {
+		if compare[i] != availability[i] {
+			t.Error("Received availability didn't match expectations:", compare[i], availability[i])
+		}
+	}
+}
+
+func TestGetAvailabilityInvalidDate(t *testing.T) {
+	w, r, _ := makeRequest(nil, map[string]string{"id": "1"})
+	r.URL.RawQuery = "from=29.8.2019"
+	getAvailability(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	commonReply := []map[string]interface{}{{
+		"id":               1,
+		"name":             "Class #1",
+		"start_date":       time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":         time.Date(2019, 10, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":         20,
+		"weekdays":         "TU",
+		"start_time":       "18:30",
+		"duration_minutes": 60,
+		"timezone":         "Europe/Helsinki",
+	}}
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply(commonReply)
+
+	w, r, _ := makeRequest(nil, map[string]string{"id": "1"})
+	r.URL.RawQuery = "from=2019-10-20&to=2019-11-05"
+	getSessions(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var sessions []map[string]interface{}
+	err = json.Unmarshal(body, &sessions)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	// Only Tuesdays until class end date, keeping 18:30 local time over the end of DST
+	compare := []map[string]interface{}{{
+		"date":        "2019-10-22",
+		"start_local": "2019-10-22T18:30:00+03:00",
+		"end_local":   "2019-10-22T19:30:00+03:00",
+		"start_utc":   "2019-10-22T15:30:00Z",
+		"end_utc":     "2019-10-22T16:30:00Z",
+	}, {
+		"date":        "2019-10-29",
+		"start_local": "2019-10-29T18:30:00+02:00",
+		"end_local":   "2019-10-29T19:30:00+02:00",
+		"start_utc":   "2019-10-29T16:30:00Z",
+		"end_utc":     "2019-10-29T17:30:00Z",
+	}}
+	if len(compare) != len(sessions) {
+		t.Fatal("Received sessions didn't match expectations:", compare, sessions)
+	}
+	for i := range compare {
+		for key, value := range compare[i] {
+			if sessions[i][key] != value {
+				t.Errorf("Received session %s didn't match expectations: %v != %v", key, value, sessions[i][key])
+			}
+		}
+	}
+}
+
 func makeRequest(requestData *Class, vars map[string]string) (*httptest.ResponseRecorder, *http.Request, error) {
 	requestBody, err := json.Marshal(&requestData)
 	if err != nil {
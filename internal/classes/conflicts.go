@@ -0,0 +1,99 @@
+package classes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Conflict session of another class held at the same time by an instructor or in a room
+// the class needs, with InstructorID or RoomID telling which
+type Conflict struct {
+	InstructorID uint64 `json:"instructor_id,omitempty"`
+	RoomID       uint64 `json:"room_id,omitempty"`
+	ClassID      uint64 `json:"class_id"`
+	ClassName    string `json:"class_name"`
+	Date         string `json:"date"`
+}
+
+// Describe conflicts for an error message
+func describeConflicts(conflicts []Conflict) string {
+	descriptions := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		if conflict.RoomID != 0 {
+			descriptions[i] = fmt.Sprintf("room %d is already taken by %s (class %d) on %s",
+				conflict.RoomID, conflict.ClassName, conflict.ClassID, conflict.Date)
+		} else {
+			descriptions[i] = fmt.Sprintf("instructor %d already teaches %s (class %d) on %s",
+				conflict.InstructorID, conflict.ClassName, conflict.ClassID, conflict.Date)
+		}
+	}
+	return "Overlapping sessions: " + strings.Join(descriptions, ", ")
+}
+
+// Time span of session, the whole day in the class timezone for classes without a start time
+func (c *Class) span(session Session) (time.Time, time.Time) {
+	if !session.Start.IsZero() {
+		return session.Start, session.End
+	}
+
+	start := time.Date(session.Date.Year(), session.Date.Month(), session.Date.Day(), 0, 0, 0, 0, c.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// First date between from and to on which class and other hold sessions at overlapping times.
+// Sessions are compared as instants, also against sessions of other dated a day apart, as those
+// overlap when either class runs past midnight or is held in another timezone.
+func (c *Class) firstOverlap(calendar Calendar, other *Class, otherCalendar Calendar, from time.Time, to time.Time) (time.Time, bool) {
+	otherSessions := map[time.Time][]Session{}
+	for _, session := range other.Sessions(from.AddDate(0, 0, -1), to.AddDate(0, 0, 1), otherCalendar) {
+		if !session.Cancelled {
+			otherSessions[session.Date] = append(otherSessions[session.Date], session)
+		}
+	}
+
+	for _, session := range c.Sessions(from, to, calendar) {
+		if session.Cancelled {
+			continue
+		}
+
+		start, end := c.span(session)
+		for _, date := range []time.Time{session.Date.AddDate(0, 0, -1), session.Date, session.Date.AddDate(0, 0, 1)} {
+			for _, otherSession := range otherSessions[date] {
+				otherStart, otherEnd := other.span(otherSession)
+				if start.UTC().Before(otherEnd.UTC()) && otherStart.UTC().Before(end.UTC()) {
+					return session.Date, true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// First date on which class and other hold sessions at overlapping times, taking the
+// exceptions and blackouts of both into account
+func firstOverlapWith(tx *gorm.DB, class *Class, other *Class) (time.Time, bool, error) {
+	from, to := DateOf(class.StartDate), DateOf(class.EndDate)
+	if other.StartDate.After(from) {
+		from = DateOf(other.StartDate)
+	}
+	if other.EndDate.Before(to) {
+		to = DateOf(other.EndDate)
+	}
+
+	calendar, err := GetCalendar(tx, class.ID, from, to)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	// Sessions of other a day outside the period can still overlap
+	otherCalendar, err := GetCalendar(tx, other.ID, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	date, overlaps := class.firstOverlap(calendar, other, otherCalendar, from, to)
+	return date, overlaps, nil
+}
@@ -3,10 +3,12 @@ package classes
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/blackouts"
 	"github.com/teeaa/studio/internal/helpers"
 )
 
@@ -24,15 +26,79 @@ func SetupExternally(database Database) {
 
 // GetClassByID get class from class db
 func GetClassByID(classID uint64) (Class, error) {
-	var class Class
-	err := db.First(&class, classID).Error
+	classes := make([]Class, 1)
+	err := db.First(&classes[0], classID).Error
+	if err == nil {
+		err = loadRoomCapacities(db.DB, classes)
+	}
 
 	if err != nil {
 		log.Error("Error retrieving class from db:", err)
 		return Class{}, err
 	}
 
-	return class, nil
+	return classes[0], nil
+}
+
+// LockClassByID get class inside transaction tx and lock its row until the transaction ends
+func LockClassByID(tx *gorm.DB, classID uint64) (Class, error) {
+	classes := make([]Class, 1)
+	err := tx.Set("gorm:query_option", "FOR UPDATE").First(&classes[0], classID).Error
+	if err == nil {
+		err = loadRoomCapacities(tx, classes)
+	}
+
+	if err != nil {
+		return Class{}, err
+	}
+
+	return classes[0], nil
+}
+
+// GetCalendar get schedule changes and blackouts of a class affecting dates between from and to
+func GetCalendar(tx *gorm.DB, classID uint64, from time.Time, to time.Time) (Calendar, error) {
+	var calendar Calendar
+	err := tx.Where("class_id = ? AND (date BETWEEN ? AND ? OR moved_to BETWEEN ? AND ?)", classID, from, to, from, to).
+		Order("date ASC").
+		Find(&calendar.Exceptions).Error
+	if err != nil {
+		return calendar, err
+	}
+
+	calendar.Blackouts, err = blackouts.GetOverlapping(tx, from, to)
+	return calendar, err
+}
+
+// GetExceptionsOfClasses get schedule exceptions of the given classes on the given dates
+func GetExceptionsOfClasses(tx *gorm.DB, classIDs []uint64, dates []time.Time) ([]Exception, error) {
+	var exceptions []Exception
+	err := tx.Where("class_id IN (?) AND date IN (?)", classIDs, dates).Find(&exceptions).Error
+
+	return exceptions, err
+}
+
+// Count bookings of a class per date between from and to with a single aggregate query.
+// Classes can't import bookings, so the bookings table is queried directly. Cancelled bookings don't take up spots.
+func (db *Database) countBookingsByDate(classID uint64, from time.Time, to time.Time) (map[string]uint, error) {
+	var rows []struct {
+		BookingDate time.Time
+		Booked      uint
+	}
+	err := db.Table("bookings").
+		Select("booking_date, count(*) AS booked").
+		Where("class_id = ? AND booking_date BETWEEN ? AND ? AND status <> ?", classID, from, to, "cancelled").
+		Group("booking_date").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	booked := map[string]uint{}
+	for _, row := range rows {
+		booked[row.BookingDate.Format("2006-01-02")] = row.Booked
+	}
+
+	return booked, nil
 }
 
 // Get class from database by id in request and handle error situations
@@ -57,5 +123,100 @@ func (db *Database) getClassFromReq(w http.ResponseWriter, r *http.Request) (*Cl
 		}
 		return nil, err
 	}
-	return &class, nil
+	classes := []Class{class}
+	err = loadDetails(db.DB, classes)
+	if err != nil {
+		log.Error("Error fetching details of class from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return nil, err
+	}
+	return &classes[0], nil
+}
+
+// Insert or update class with its instructors and facets in one transaction. When its room is taken or
+// instructors already teach overlapping sessions of other classes, nothing is saved and the
+// conflicts are returned.
+func (db *Database) saveClass(class *Class) ([]Conflict, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	err := resolveFacets(tx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Save(class).Error
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := checkRoom(tx, class)
+	if err != nil || len(conflicts) > 0 {
+		return conflicts, err
+	}
+
+	conflicts, err = checkInstructors(tx, class)
+	if err != nil || len(conflicts) > 0 {
+		return conflicts, err
+	}
+
+	err = saveInstructors(tx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	err = saveTags(tx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	return conflicts, tx.Commit().Error
+}
+
+// Get exception of the requested class from database by id in request and handle error situations
+func (db *Database) getExceptionFromReq(w http.ResponseWriter, r *http.Request, class *Class) (*Exception, error) {
+	var exception Exception
+	vars := mux.Vars(r)
+	exceptionID, err := strconv.Atoi(vars["exceptionID"])
+	if err != nil {
+		log.Warnf("Requested exception id (%s) is not an integer: %s", vars["exceptionID"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid exception ID")
+		return nil, err
+	}
+
+	err = db.Where("class_id = ?", class.ID).First(&exception, exceptionID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested exception by id %d does not exist", exceptionID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Exception does not exist")
+		} else {
+			log.Error("Error fetching exception from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &exception, nil
+}
+
+// GetExceptionFromReq get class and its exception by ids in request and handle error situations
+func GetExceptionFromReq(w http.ResponseWriter, r *http.Request) (*Class, *Exception, error) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exception, err := db.getExceptionFromReq(w, r, class)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return class, exception, nil
+}
+
+// GetClassFromReq get class by id in request and handle error situations
+func GetClassFromReq(w http.ResponseWriter, r *http.Request) (*Class, error) {
+	return db.getClassFromReq(w, r)
 }
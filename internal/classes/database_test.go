@@ -1,6 +1,7 @@
 package classes
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -24,13 +25,13 @@ func TestGetClassById(t *testing.T) {
 	}
 
 	compare := Class{
-		1,
-		"Class #1",
-		time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
-		20,
+		ID:        1,
+		Name:      "Class #1",
+		StartDate: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  20,
 	}
-	if compare != class {
+	if !reflect.DeepEqual(compare, class) {
 		t.Error("Retrieved class data didn't match expectations:", compare, class)
 	}
 }
@@ -44,13 +45,15 @@ func TestGetClassFromReq(t *testing.T) {
 		t.Error("Error getting class by request vars")
 	}
 	compare := &Class{
-		1,
-		"Class #1",
-		time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
-		time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
-		20,
+		ID:            1,
+		Name:          "Class #1",
+		StartDate:     time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:      20,
+		InstructorIDs: []uint64{},
+		Tags:          []string{},
 	}
-	if *compare != *class {
+	if !reflect.DeepEqual(*compare, *class) {
 		t.Error("Retrieved class data didn't match expectations:", *compare, *class)
 	}
 }
@@ -0,0 +1,169 @@
+package classes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Check exception fits the weekly schedule of class and doesn't clash with its other exceptions
+func checkException(exception *Exception, class *Class) (int, error) {
+	if !class.MeetsOn(exception.Date) {
+		return http.StatusBadRequest, errors.New("Class is not held on the date")
+	}
+
+	from, to := exception.Date, exception.Date
+	if exception.MovedTo != nil {
+		if exception.MovedTo.Equal(exception.Date) {
+			return http.StatusBadRequest, errors.New("Session can't be moved to the same date")
+		}
+		if exception.MovedTo.Before(from) {
+			from = *exception.MovedTo
+		} else {
+			to = *exception.MovedTo
+		}
+	}
+
+	calendar, err := GetCalendar(db.DB, class.ID, from, to)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	for _, other := range calendar.Exceptions {
+		if other.ID == exception.ID {
+			continue
+		}
+		if DateOf(other.Date).Equal(exception.Date) {
+			return http.StatusConflict, errors.New("Session on the date already has an exception")
+		}
+		if exception.MovedTo != nil && other.MovedTo != nil && DateOf(*other.MovedTo).Equal(*exception.MovedTo) {
+			return http.StatusConflict, errors.New("Another session is already moved to moved_to")
+		}
+	}
+
+	if exception.MovedTo != nil && class.MeetsOn(*exception.MovedTo) && calendar.exceptionOn(*exception.MovedTo) == nil {
+		return http.StatusConflict, errors.New("Class is already held on moved_to")
+	}
+
+	return http.StatusOK, nil
+}
+
+func getExceptions(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var exceptions []Exception
+	err = db.Where("class_id = ?", class.ID).Order("date ASC").Find(&exceptions).Error
+	if err != nil {
+		log.Error("Error fetching exceptions from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&exceptions)
+}
+
+func addException(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var exception Exception
+	err = json.NewDecoder(r.Body).Decode(&exception)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new exception: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for exception")
+		return
+	}
+	exception.ClassID = class.ID
+
+	code, err := checkException(&exception, class)
+	if code == http.StatusInternalServerError {
+		log.Error("Error checking exception against db: ", err)
+		helpers.ResponseJSON(w, code, "Something went wrong")
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, code, err.Error())
+		return
+	}
+
+	err = db.Create(&exception).Error
+	if err != nil {
+		log.Error("Error inserting exception to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	db.notifyClassChanged(class, describeException(&exception), exception.Date)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&exception)
+}
+
+func getException(w http.ResponseWriter, r *http.Request) {
+	_, exception, err := GetExceptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&exception)
+}
+
+func updateException(w http.ResponseWriter, r *http.Request) {
+	class, exception, err := GetExceptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+	previousDate := exception.Date
+
+	err = json.NewDecoder(r.Body).Decode(&exception)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating exception: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for exception")
+		return
+	}
+
+	code, err := checkException(exception, class)
+	if code == http.StatusInternalServerError {
+		log.Error("Error checking exception against db: ", err)
+		helpers.ResponseJSON(w, code, "Something went wrong")
+		return
+	}
+	if err != nil {
+		helpers.ResponseJSON(w, code, err.Error())
+		return
+	}
+
+	err = db.Save(&exception).Error
+	if err != nil {
+		log.Error("Error saving exception to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	db.notifyClassChanged(class, describeException(exception), previousDate, exception.Date)
+
+	json.NewEncoder(w).Encode(&exception)
+}
+
+func deleteException(w http.ResponseWriter, r *http.Request) {
+	class, exception, err := GetExceptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = db.Delete(&exception).Error
+	if err != nil {
+		log.Error("Error deleting exception from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	db.notifyClassChanged(class, fmt.Sprintf("The session on %s will be held as scheduled.", exception.Date.Format("2006-01-02")), exception.Date)
+
+	helpers.ResponseJSON(w, 200, "Exception removed")
+}
@@ -0,0 +1,119 @@
+package classes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+// Mock class held on Tuesdays for exceptions to apply to
+func setTuesdayClassMatch() {
+	commonReply := []map[string]interface{}{{
+		"id":         1,
+		"name":       "Class #1",
+		"start_date": time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+		"weekdays":   "TU",
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply(commonReply)
+}
+
+func TestAddException(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	setTuesdayClassMatch()
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "class_exceptions"`).WithID(4)
+
+	w, r := makeExceptionRequest(map[string]string{"date": "2019-08-20", "moved_to": "2019-08-22", "reason": "Public holiday"}, map[string]string{"id": "1"})
+	addException(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var exception map[string]interface{}
+	err = json.Unmarshal(body, &exception)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if exception["id"] != float64(4) || exception["class_id"] != float64(1) || exception["date"] != "2019-08-20" || exception["moved_to"] != "2019-08-22" {
+		t.Error("Received exception didn't match expectations:", exception)
+	}
+}
+
+func TestAddExceptionNotHeld(t *testing.T) {
+	mocket.Catcher.Reset()
+	setTuesdayClassMatch()
+
+	// 2019-08-21 is a Wednesday
+	w, r := makeExceptionRequest(map[string]string{"date": "2019-08-21"}, map[string]string{"id": "1"})
+	addException(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestAddExceptionMovedOntoSession(t *testing.T) {
+	mocket.Catcher.Reset()
+	setTuesdayClassMatch()
+
+	w, r := makeExceptionRequest(map[string]string{"date": "2019-08-20", "moved_to": "2019-08-27"}, map[string]string{"id": "1"})
+	addException(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestDeleteException(t *testing.T) {
+	mocket.Catcher.Reset()
+	setTuesdayClassMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_exceptions"  WHERE (class_id = 1) AND ("class_exceptions"."id" = 4)`).WithReply([]map[string]interface{}{{
+		"id":       4,
+		"class_id": 1,
+		"date":     time.Date(2019, 8, 13, 0, 0, 0, 0, time.UTC),
+	}})
+
+	w, r := makeExceptionRequest(nil, map[string]string{"id": "1", "exceptionID": "4"})
+	deleteException(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+}
+
+func TestDeleteExceptionNonExisting(t *testing.T) {
+	w, r := makeExceptionRequest(nil, map[string]string{"id": "1", "exceptionID": "345"})
+	deleteException(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeExceptionRequest(requestData map[string]string, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/classes/1/exceptions", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,262 @@
+package classes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Style dance style of classes, such as ballet or jazz
+type Style struct {
+	ID   uint64 `gorm:"primary_key" json:"id"`
+	Name string `gorm:"type:varchar(255);unique_index" json:"name"`
+}
+
+// Level skill level of classes. Position orders levels from beginner to advanced.
+type Level struct {
+	ID       uint64 `gorm:"primary_key" json:"id"`
+	Name     string `gorm:"type:varchar(255);unique_index" json:"name"`
+	Position uint   `json:"position"`
+}
+
+// Tag free-form tag of classes, such as kids
+type Tag struct {
+	ID   uint64 `gorm:"primary_key" json:"id"`
+	Name string `gorm:"type:varchar(255);unique_index" json:"name"`
+}
+
+// ClassTag tagging of a class
+type ClassTag struct {
+	ClassID uint64 `gorm:"primary_key;auto_increment:false"`
+	TagID   uint64 `gorm:"primary_key;auto_increment:false"`
+}
+
+// TableName to keep class tags next to classes
+func (ClassTag) TableName() string {
+	return "class_tags"
+}
+
+// Facet value classes can be filtered by, with the number of classes having it
+type Facet struct {
+	Name    string `json:"name"`
+	Classes uint   `json:"classes"`
+}
+
+// Facets values of each filter of classes, for building filter menus
+type Facets struct {
+	Styles []Facet `json:"styles"`
+	Levels []Facet `json:"levels"`
+	Tags   []Facet `json:"tags"`
+}
+
+// ErrNoSuchLevel is returned when giving a class a level which doesn't exist
+var ErrNoSuchLevel = errors.New("No such level")
+
+// Styles, levels and tags are compared by their trimmed lowercase name
+func normaliseFacet(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Load style, level and tags of classes
+func loadFacets(tx *gorm.DB, classes []Class) error {
+	if len(classes) == 0 {
+		return nil
+	}
+
+	classIDs := make([]uint64, len(classes))
+	styleIDs := []uint64{}
+	levelIDs := []uint64{}
+	for i := range classes {
+		classIDs[i] = classes[i].ID
+		classes[i].Style, classes[i].Level, classes[i].Tags = "", "", []string{}
+		if classes[i].StyleID != nil {
+			styleIDs = append(styleIDs, *classes[i].StyleID)
+		}
+		if classes[i].LevelID != nil {
+			levelIDs = append(levelIDs, *classes[i].LevelID)
+		}
+	}
+
+	styles := map[uint64]string{}
+	if len(styleIDs) > 0 {
+		var found []Style
+		err := tx.Where("id IN (?)", styleIDs).Find(&found).Error
+		if err != nil {
+			return err
+		}
+		for _, style := range found {
+			styles[style.ID] = style.Name
+		}
+	}
+
+	levels := map[uint64]string{}
+	if len(levelIDs) > 0 {
+		var found []Level
+		err := tx.Where("id IN (?)", levelIDs).Find(&found).Error
+		if err != nil {
+			return err
+		}
+		for _, level := range found {
+			levels[level.ID] = level.Name
+		}
+	}
+
+	var tags []struct {
+		ClassID uint64
+		Name    string
+	}
+	err := tx.Table("class_tags").
+		Select("class_tags.class_id, tags.name").
+		Joins("JOIN tags ON tags.id = class_tags.tag_id").
+		Where("class_tags.class_id IN (?)", classIDs).
+		Order("tags.name ASC").
+		Scan(&tags).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range classes {
+		if classes[i].StyleID != nil {
+			classes[i].Style = styles[*classes[i].StyleID]
+		}
+		if classes[i].LevelID != nil {
+			classes[i].Level = levels[*classes[i].LevelID]
+		}
+		for _, tag := range tags {
+			if tag.ClassID == classes[i].ID {
+				classes[i].Tags = append(classes[i].Tags, tag.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Set style and level ids of class by their names. New styles are added,
+// but levels are a fixed scale and have to exist.
+func resolveFacets(tx *gorm.DB, class *Class) error {
+	class.StyleID = nil
+	if class.Style != "" {
+		style := Style{Name: class.Style}
+		err := tx.Where("name = ?", style.Name).FirstOrCreate(&style).Error
+		if err != nil {
+			return err
+		}
+		class.StyleID = &style.ID
+	}
+
+	class.LevelID = nil
+	if class.Level != "" {
+		var level Level
+		err := tx.Where("name = ?", class.Level).First(&level).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return ErrNoSuchLevel
+		}
+		if err != nil {
+			return err
+		}
+		class.LevelID = &level.ID
+	}
+
+	return nil
+}
+
+// Replace the tags of class, adding tags which don't exist yet
+func saveTags(tx *gorm.DB, class *Class) error {
+	err := tx.Where("class_id = ?", class.ID).Delete(&ClassTag{}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, name := range class.Tags {
+		tag := Tag{Name: name}
+		err = tx.Where("name = ?", tag.Name).FirstOrCreate(&tag).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&ClassTag{ClassID: class.ID, TagID: tag.ID}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Filter classes by the style, level and tag query parameters. Several styles or levels
+// match classes with any of them, while several tags match classes having all of them.
+func filterClasses(query *gorm.DB, params url.Values) *gorm.DB {
+	normalise := func(values []string) []string {
+		normalised := []string{}
+		for _, value := range values {
+			if value = normaliseFacet(value); value != "" {
+				normalised = append(normalised, value)
+			}
+		}
+		return normalised
+	}
+
+	if styles := normalise(params["style"]); len(styles) > 0 {
+		query = query.Where("style_id IN (SELECT id FROM styles WHERE name IN (?))", styles)
+	}
+	if levels := normalise(params["level"]); len(levels) > 0 {
+		query = query.Where("level_id IN (SELECT id FROM levels WHERE name IN (?))", levels)
+	}
+	for _, tag := range normalise(params["tag"]) {
+		query = query.Where("id IN (SELECT class_tags.class_id FROM class_tags JOIN tags ON tags.id = class_tags.tag_id WHERE tags.name = ?)", tag)
+	}
+
+	return query
+}
+
+// Count classes per style, level and tag
+func (db *Database) getFacets() (Facets, error) {
+	facets := Facets{[]Facet{}, []Facet{}, []Facet{}}
+
+	err := db.Table("styles").
+		Select("styles.name, count(classes.id) AS classes").
+		Joins("LEFT JOIN classes ON classes.style_id = styles.id").
+		Group("styles.id, styles.name").
+		Order("styles.name ASC").
+		Scan(&facets.Styles).Error
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Table("levels").
+		Select("levels.name, count(classes.id) AS classes").
+		Joins("LEFT JOIN classes ON classes.level_id = levels.id").
+		Group("levels.id, levels.name, levels.position").
+		Order("levels.position ASC").
+		Scan(&facets.Levels).Error
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Table("tags").
+		Select("tags.name, count(class_tags.class_id) AS classes").
+		Joins("LEFT JOIN class_tags ON class_tags.tag_id = tags.id").
+		Group("tags.id, tags.name").
+		Order("tags.name ASC").
+		Scan(&facets.Tags).Error
+
+	return facets, err
+}
+
+func getFacets(w http.ResponseWriter, r *http.Request) {
+	facets, err := db.getFacets()
+	if err != nil {
+		log.Error("Error fetching class facets from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&facets)
+}
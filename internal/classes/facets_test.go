@@ -0,0 +1,115 @@
+package classes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func TestGetClassesFiltered(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	var query string
+	var args []interface{}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"`).WithCallback(func(q string, a []driver.NamedValue) {
+		query = q
+		for _, arg := range a {
+			args = append(args, arg.Value)
+		}
+	})
+
+	r := httptest.NewRequest("GET", "/classes?style=Ballet&level=beginner&tag=kids&tag=%20Teens", nil)
+	w := httptest.NewRecorder()
+	getClasses(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	if !strings.Contains(query, "style_id IN (SELECT id FROM styles") || !strings.Contains(query, "level_id IN (SELECT id FROM levels") ||
+		strings.Count(query, "JOIN tags ON") != 2 {
+		t.Error("Expected classes to be filtered by style, level and both tags:", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"ballet", "beginner", "kids", "teens"}) {
+		t.Error("Expected normalised filter values, got:", args)
+	}
+}
+
+func TestAddClassWithFacets(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "styles"`).WithReply([]map[string]interface{}{{"id": 2, "name": "ballet"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "levels"`).WithReply([]map[string]interface{}{{"id": 1, "name": "beginner", "position": 1}})
+	var inserted []string
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "tags"`).WithID(5).WithCallback(func(q string, a []driver.NamedValue) {
+		inserted = append(inserted, a[0].Value.(string))
+	})
+	var classInsert []driver.NamedValue
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "classes"`).WithCallback(func(q string, a []driver.NamedValue) {
+		classInsert = a
+	})
+
+	requestData := Class{
+		Name:      "Ballet for kids",
+		StartDate: time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  12,
+		Style:     " Ballet",
+		Level:     "Beginner",
+		Tags:      []string{"Kids", "kids "},
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var class Class
+	err = json.Unmarshal(body, &class)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if class.Style != "ballet" || class.Level != "beginner" || !reflect.DeepEqual(class.Tags, []string{"kids"}) {
+		t.Error("Received class facets didn't match expectations:", class)
+	}
+
+	if !reflect.DeepEqual(inserted, []string{"kids"}) {
+		t.Error("Expected the new tag to be added once, added:", inserted)
+	}
+	if len(classInsert) < 12 || classInsert[10].Value != int64(2) || classInsert[11].Value != int64(1) {
+		t.Error("Expected style and level ids to be stored with the class:", classInsert)
+	}
+}
+
+func TestAddClassNoSuchLevel(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	requestData := Class{
+		Name:      "Ballet",
+		StartDate: time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  12,
+		Level:     "expert",
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
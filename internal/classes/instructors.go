@@ -0,0 +1,141 @@
+package classes
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	"github.com/teeaa/studio/internal/instructors"
+)
+
+// ClassInstructor assignment of an instructor to teach a class
+type ClassInstructor struct {
+	ClassID      uint64 `gorm:"primary_key;auto_increment:false"`
+	InstructorID uint64 `gorm:"primary_key;auto_increment:false"`
+}
+
+// TableName to keep instructor assignments next to classes
+func (ClassInstructor) TableName() string {
+	return "class_instructors"
+}
+
+// ErrNoSuchInstructor is returned when assigning an instructor who doesn't exist
+var ErrNoSuchInstructor = errors.New("No such instructor")
+
+// Load instructors, facets and room capacities of classes
+func loadDetails(tx *gorm.DB, classes []Class) error {
+	err := loadInstructorIDs(tx, classes)
+	if err != nil {
+		return err
+	}
+
+	err = loadRoomCapacities(tx, classes)
+	if err != nil {
+		return err
+	}
+
+	return loadFacets(tx, classes)
+}
+
+// Load ids of the instructors teaching classes
+func loadInstructorIDs(tx *gorm.DB, classes []Class) error {
+	if len(classes) == 0 {
+		return nil
+	}
+
+	classIDs := make([]uint64, len(classes))
+	for i := range classes {
+		classIDs[i] = classes[i].ID
+		classes[i].InstructorIDs = []uint64{}
+	}
+
+	var assignments []ClassInstructor
+	err := tx.Where("class_id IN (?)", classIDs).Order("instructor_id ASC").Find(&assignments).Error
+	if err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		for i := range classes {
+			if classes[i].ID == assignment.ClassID {
+				classes[i].InstructorIDs = append(classes[i].InstructorIDs, assignment.InstructorID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check the instructors of class exist and don't already teach other classes at the same time.
+// Called in the transaction saving the class, and locks the instructor rows until it ends so
+// that concurrent assignments of the same instructor are checked one at a time.
+func checkInstructors(tx *gorm.DB, class *Class) ([]Conflict, error) {
+	conflicts := []Conflict{}
+	if len(class.InstructorIDs) == 0 {
+		return conflicts, nil
+	}
+
+	found, err := instructors.LockInstructorsByIDs(tx, class.InstructorIDs)
+	if err != nil {
+		return conflicts, err
+	}
+	if len(found) != len(class.InstructorIDs) {
+		return conflicts, ErrNoSuchInstructor
+	}
+
+	var assignments []ClassInstructor
+	err = tx.Where("instructor_id IN (?) AND class_id <> ?", class.InstructorIDs, class.ID).Find(&assignments).Error
+	if err != nil || len(assignments) == 0 {
+		return conflicts, err
+	}
+
+	otherIDs := make([]uint64, len(assignments))
+	for i, assignment := range assignments {
+		otherIDs[i] = assignment.ClassID
+	}
+
+	var others []Class
+	err = tx.Where("id IN (?) AND start_date <= ? AND end_date >= ?", otherIDs, class.EndDate, class.StartDate).Find(&others).Error
+	if err != nil {
+		return conflicts, err
+	}
+
+	for i := range others {
+		date, overlaps, err := firstOverlapWith(tx, class, &others[i])
+		if err != nil {
+			return conflicts, err
+		}
+		if !overlaps {
+			continue
+		}
+
+		for _, assignment := range assignments {
+			if assignment.ClassID == others[i].ID {
+				conflicts = append(conflicts, Conflict{
+					InstructorID: assignment.InstructorID,
+					ClassID:      others[i].ID,
+					ClassName:    others[i].Name,
+					Date:         date.Format("2006-01-02"),
+				})
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// Replace the instructor assignments of class
+func saveInstructors(tx *gorm.DB, class *Class) error {
+	err := tx.Where("class_id = ?", class.ID).Delete(&ClassInstructor{}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, instructorID := range class.InstructorIDs {
+		err = tx.Create(&ClassInstructor{ClassID: class.ID, InstructorID: instructorID}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package classes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func TestFirstOverlap(t *testing.T) {
+	class := Class{
+		StartDate:       time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:         time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Weekdays:        NewWeekdays(time.Tuesday, time.Thursday),
+		StartTime:       "18:00",
+		DurationMinutes: 60,
+		Timezone:        "UTC",
+	}
+	other := class
+	other.Weekdays = NewWeekdays(time.Thursday)
+	other.StartTime = "19:00"
+
+	_, overlaps := class.firstOverlap(Calendar{}, &other, Calendar{}, class.StartDate, class.EndDate)
+	if overlaps {
+		t.Error("Back to back sessions shouldn't overlap")
+	}
+
+	other.StartTime = "18:30"
+	date, overlaps := class.firstOverlap(Calendar{}, &other, Calendar{}, class.StartDate, class.EndDate)
+	if !overlaps || !date.Equal(time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected sessions to overlap on first Thursday, got:", date, overlaps)
+	}
+	// Late Wednesday session in New York is held at the same time as the early Thursday session in Helsinki
+	other.Weekdays = NewWeekdays(time.Wednesday)
+	other.StartTime = "23:30"
+	other.Timezone = "America/New_York"
+	class.StartTime = "06:00"
+	class.Timezone = "Europe/Helsinki"
+	date, overlaps = class.firstOverlap(Calendar{}, &other, Calendar{}, class.StartDate, class.EndDate)
+	if !overlaps || !date.Equal(time.Date(2019, 8, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected sessions dated a day apart to overlap, got:", date, overlaps)
+	}
+}
+
+func TestAddClassConflict(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "instructors"`).WithReply([]map[string]interface{}{{"id": 3, "name": "Anna"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_instructors"`).WithReply([]map[string]interface{}{{"class_id": 2, "instructor_id": 3}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE (id IN (2)`).WithReply([]map[string]interface{}{{
+		"id":               2,
+		"name":             "Salsa",
+		"start_date":       time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":         time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"weekdays":         "TH",
+		"start_time":       "18:30",
+		"duration_minutes": 60,
+		"timezone":         "UTC",
+	}})
+
+	requestData := Class{
+		Name:            "Tango",
+		StartDate:       time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:         time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:        20,
+		Weekdays:        NewWeekdays(time.Tuesday, time.Thursday),
+		StartTime:       "18:00",
+		DurationMinutes: 60,
+		InstructorIDs:   []uint64{3},
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+
+	var response struct {
+		Conflicts []Conflict `json:"conflicts"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	compare := Conflict{InstructorID: 3, ClassID: 2, ClassName: "Salsa", Date: "2019-08-01"}
+	if len(response.Conflicts) != 1 || response.Conflicts[0] != compare {
+		t.Error("Received conflicts didn't match expectations:", response.Conflicts)
+	}
+}
+
+func TestAddClassNoSuchInstructor(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	requestData := Class{
+		Name:          "Tango",
+		StartDate:     time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:      20,
+		InstructorIDs: []uint64{3},
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
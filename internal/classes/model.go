@@ -6,13 +6,223 @@ import (
 	"time"
 )
 
-// Class representation of classes.classes
+// Class representation of classes.classes. StartTime is the local wall clock time in Timezone,
+// so sessions keep their local start time across DST changes.
 type Class struct {
-	ID        uint64    `gorm:"primary_key" json:"id"`
-	Name      string    `json:"name"`
-	StartDate time.Time `gorm:"type:date" json:"start_date"`
-	EndDate   time.Time `gorm:"type:date" json:"end_date"`
-	Capacity  uint      `json:"capacity"`
+	ID              uint64    `gorm:"primary_key" json:"id"`
+	Name            string    `json:"name"`
+	StartDate       time.Time `gorm:"type:date" json:"start_date"`
+	EndDate         time.Time `gorm:"type:date" json:"end_date"`
+	Capacity        uint      `json:"capacity"`
+	Weekdays        Weekdays  `gorm:"type:varchar(20)" json:"weekdays"`
+	StartTime       string    `gorm:"type:char(5)" json:"start_time"`
+	DurationMinutes uint      `json:"duration_minutes"`
+	Timezone        string    `gorm:"type:varchar(64)" json:"timezone"`
+	// Hours before the session bookings can be cancelled free of charge, the studio policy applies when nil
+	CancellationHours *uint `json:"cancellation_hours"`
+	// Instructors teaching the class, stored in class_instructors
+	InstructorIDs []uint64 `gorm:"-" json:"instructor_ids"`
+	// Room the class is held in, or nil when it isn't placed in a room
+	RoomID *uint64 `json:"room_id"`
+	// Capacity of the room, loaded with the class
+	roomCapacity *uint
+	// Style, level and tags of the class, stored in lookup tables and given by name
+	StyleID *uint64  `json:"-"`
+	Style   string   `gorm:"-" json:"style"`
+	LevelID *uint64  `json:"-"`
+	Level   string   `gorm:"-" json:"level"`
+	Tags    []string `gorm:"-" json:"tags"`
+	// Price of a session in cents including VAT, or nil when sessions aren't sold on their own
+	Price *uint `json:"price"`
+	// VAT percentage of the price, the studio rate applies when nil
+	VATRate *float64 `json:"vat_rate"`
+}
+
+// Session a single occurrence of a class. Start and End are in the class timezone,
+// and are left zero for classes without a start time.
+type Session struct {
+	Date      time.Time
+	Start     time.Time
+	End       time.Time
+	Cancelled bool
+	Reason    string
+	MovedFrom time.Time
+	MovedTo   time.Time
+}
+
+// Exception to the weekly schedule of a class: the session on Date is cancelled,
+// and held on MovedTo instead when it's set
+type Exception struct {
+	ID      uint64     `gorm:"primary_key" json:"id"`
+	ClassID uint64     `json:"class_id"`
+	Date    time.Time  `gorm:"type:date" json:"date"`
+	MovedTo *time.Time `gorm:"type:date" json:"moved_to"`
+	Reason  string     `json:"reason"`
+}
+
+// TableName to keep exceptions next to classes
+func (Exception) TableName() string {
+	return "class_exceptions"
+}
+
+// Availability of a class on a single date
+type Availability struct {
+	Date      string `json:"date"`
+	Capacity  uint   `json:"capacity"`
+	Booked    uint   `json:"booked"`
+	Remaining uint   `json:"remaining"`
+}
+
+var studioTimezone = "UTC"
+
+// SetStudioTimezone set IANA timezone used for classes which don't define their own
+func SetStudioTimezone(name string) error {
+	_, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+
+	studioTimezone = name
+	return nil
+}
+
+// DateOf date part of t as UTC midnight, which is how dates are stored and compared
+func DateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Location of the class timezone
+func (c *Class) Location() *time.Location {
+	name := c.Timezone
+	if name == "" {
+		name = studioTimezone
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// SessionOn session of the class on date, without checking the class is held on it
+func (c *Class) SessionOn(date time.Time) Session {
+	session := Session{Date: DateOf(date)}
+	if c.StartTime == "" {
+		return session
+	}
+
+	clock, err := time.Parse("15:04", c.StartTime)
+	if err != nil {
+		return session
+	}
+
+	session.Start = time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, c.Location())
+	session.End = session.Start.Add(time.Duration(c.DurationMinutes) * time.Minute)
+	return session
+}
+
+// MeetsOn tells if the class is held on date
+func (c *Class) MeetsOn(date time.Time) bool {
+	date = DateOf(date)
+	if date.Before(DateOf(c.StartDate)) || date.After(DateOf(c.EndDate)) {
+		return false
+	}
+
+	return c.Weekdays.Has(date.Weekday())
+}
+
+// MarshalJSON to output session times both in class local time and in UTC
+func (s Session) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		Date       string `json:"date"`
+		StartLocal string `json:"start_local,omitempty"`
+		EndLocal   string `json:"end_local,omitempty"`
+		StartUTC   string `json:"start_utc,omitempty"`
+		EndUTC     string `json:"end_utc,omitempty"`
+		Cancelled  bool   `json:"cancelled"`
+		Reason     string `json:"reason,omitempty"`
+		MovedFrom  string `json:"moved_from,omitempty"`
+		MovedTo    string `json:"moved_to,omitempty"`
+	}{
+		Date:      s.Date.Format("2006-01-02"),
+		Cancelled: s.Cancelled,
+		Reason:    s.Reason,
+	}
+
+	if !s.Start.IsZero() {
+		aux.StartLocal = s.Start.Format(time.RFC3339)
+		aux.EndLocal = s.End.Format(time.RFC3339)
+		aux.StartUTC = s.Start.UTC().Format(time.RFC3339)
+		aux.EndUTC = s.End.UTC().Format(time.RFC3339)
+	}
+	if !s.MovedFrom.IsZero() {
+		aux.MovedFrom = s.MovedFrom.Format("2006-01-02")
+	}
+	if !s.MovedTo.IsZero() {
+		aux.MovedTo = s.MovedTo.Format("2006-01-02")
+	}
+
+	return json.Marshal(&aux)
+}
+
+// MarshalJSON to date correctly
+func (e *Exception) MarshalJSON() ([]byte, error) {
+	type Alias Exception
+	var movedTo *string
+	if e.MovedTo != nil {
+		date := e.MovedTo.Format("2006-01-02")
+		movedTo = &date
+	}
+
+	return json.Marshal(&struct {
+		Date    string  `json:"date"`
+		MovedTo *string `json:"moved_to"`
+		*Alias
+	}{
+		Date:    e.Date.Format("2006-01-02"),
+		MovedTo: movedTo,
+		Alias:   (*Alias)(e),
+	})
+}
+
+// UnmarshalJSON to date correctly and only accept the fields which can be changed
+func (e *Exception) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		Date    string `json:"date"`
+		MovedTo string `json:"moved_to"`
+		Reason  string `json:"reason"`
+	}{}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if len(aux.Date) < 10 {
+		return errors.New("Invalid date in payload")
+	}
+
+	e.Date, err = time.Parse("2006-01-02", aux.Date[0:10])
+	if err != nil {
+		return err
+	}
+
+	e.MovedTo = nil
+	if aux.MovedTo != "" {
+		if len(aux.MovedTo) < 10 {
+			return errors.New("Invalid moved_to in payload")
+		}
+
+		movedTo, err := time.Parse("2006-01-02", aux.MovedTo[0:10])
+		if err != nil {
+			return err
+		}
+		e.MovedTo = &movedTo
+	}
+	e.Reason = aux.Reason
+
+	return nil
 }
 
 // MarshalJSON to date correctly
@@ -50,6 +260,52 @@ func (c *Class) UnmarshalJSON(data []byte) error {
 		return errors.New("Invalid capacity in payload, must to be over 0")
 	}
 
+	if c.StartTime != "" {
+		_, err = time.Parse("15:04", c.StartTime)
+		if err != nil {
+			return errors.New("Invalid start_time in payload, expected HH:MM")
+		}
+	}
+
+	seen := map[uint64]bool{}
+	instructorIDs := []uint64{}
+	for _, instructorID := range c.InstructorIDs {
+		if !seen[instructorID] {
+			seen[instructorID] = true
+			instructorIDs = append(instructorIDs, instructorID)
+		}
+	}
+	c.InstructorIDs = instructorIDs
+
+	if c.VATRate != nil && (*c.VATRate < 0 || *c.VATRate >= 100) {
+		return errors.New("Invalid vat_rate in payload, expected a percentage from 0 to 100")
+	}
+
+	if c.RoomID != nil && *c.RoomID == 0 {
+		c.RoomID = nil
+	}
+
+	c.Style = normaliseFacet(c.Style)
+	c.Level = normaliseFacet(c.Level)
+	seenTags := map[string]bool{}
+	tags := []string{}
+	for _, tag := range c.Tags {
+		tag = normaliseFacet(tag)
+		if tag != "" && !seenTags[tag] {
+			seenTags[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	c.Tags = tags
+
+	if c.Timezone == "" {
+		c.Timezone = studioTimezone
+	}
+	_, err = time.LoadLocation(c.Timezone)
+	if err != nil {
+		return errors.New("Invalid timezone in payload, expected an IANA timezone such as Europe/Helsinki")
+	}
+
 	if aux.StartDate != "" {
 		if len(aux.StartDate) < 10 {
 			return errors.New("Invalid start_date in payload")
@@ -0,0 +1,71 @@
+package classes
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/notify"
+)
+
+// Tells whether the change from previous to class affects when or where sessions are held
+func scheduleChanged(previous Class, class Class) bool {
+	return previous.StartDate != class.StartDate || previous.EndDate != class.EndDate ||
+		previous.Weekdays != class.Weekdays || previous.StartTime != class.StartTime ||
+		previous.DurationMinutes != class.DurationMinutes || previous.Timezone != class.Timezone ||
+		(previous.RoomID == nil) != (class.RoomID == nil) ||
+		(previous.RoomID != nil && class.RoomID != nil && *previous.RoomID != *class.RoomID)
+}
+
+// Describe the change an exception makes to the session on its date
+func describeException(exception *Exception) string {
+	var change string
+	if exception.MovedTo != nil {
+		change = fmt.Sprintf("The session on %s has been moved to %s.", exception.Date.Format("2006-01-02"), exception.MovedTo.Format("2006-01-02"))
+	} else {
+		change = fmt.Sprintf("The session on %s has been cancelled.", exception.Date.Format("2006-01-02"))
+	}
+	if exception.Reason != "" {
+		change += " Reason: " + exception.Reason
+	}
+	return change
+}
+
+// Notify members with active bookings of the class about change. Only bookings on dates are
+// considered when given, otherwise all upcoming ones. Classes can't import bookings, so the
+// bookings table is queried directly. Failures are only logged, as the change has been saved.
+func (db *Database) notifyClassChanged(class *Class, change string, dates ...time.Time) {
+	if !notify.Enabled() {
+		return
+	}
+
+	var recipients []struct {
+		Name  string
+		Email string
+	}
+	query := db.Table("bookings").
+		Select("DISTINCT members.name, members.email").
+		Joins("JOIN members ON members.id = bookings.member_id").
+		Where("bookings.class_id = ? AND bookings.status IN (?) AND members.email IS NOT NULL", class.ID, []string{"pending", "confirmed"})
+	if len(dates) > 0 {
+		query = query.Where("bookings.booking_date IN (?)", dates)
+	} else {
+		query = query.Where("bookings.booking_date >= ?", DateOf(time.Now().In(class.Location())))
+	}
+	err := query.Scan(&recipients).Error
+	if err != nil {
+		log.Errorf("Error fetching members to notify about changes to class %d: %s", class.ID, err)
+		return
+	}
+
+	for _, recipient := range recipients {
+		err = notify.Send(notify.EventClassChanged, recipient.Email, notify.ClassNotice{
+			MemberName: recipient.Name,
+			ClassName:  class.Name,
+			Change:     change,
+		})
+		if err != nil {
+			log.Errorf("Error notifying %s about changes to class %d: %s", recipient.Email, class.ID, err)
+		}
+	}
+}
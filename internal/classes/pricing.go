@@ -0,0 +1,149 @@
+package classes
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// SessionPrice price of a single session of a class, overriding the price of the class.
+// Prices are in cents and include VAT.
+type SessionPrice struct {
+	ClassID uint64    `gorm:"primary_key;auto_increment:false" json:"class_id"`
+	Date    time.Time `gorm:"primary_key;type:date" json:"date"`
+	Price   uint      `json:"price"`
+}
+
+// TableName to keep session prices next to classes
+func (SessionPrice) TableName() string {
+	return "class_session_prices"
+}
+
+// MarshalJSON to date correctly
+func (p *SessionPrice) MarshalJSON() ([]byte, error) {
+	type Alias SessionPrice
+	return json.Marshal(&struct {
+		Date string `json:"date"`
+		*Alias
+	}{
+		Date:  p.Date.Format("2006-01-02"),
+		Alias: (*Alias)(p),
+	})
+}
+
+// PriceOn price of the session of class on date inside transaction tx, or nil when
+// the session isn't sold on its own
+func PriceOn(tx *gorm.DB, class *Class, date time.Time) (*uint, error) {
+	var sessionPrice SessionPrice
+	err := tx.Where("class_id = ? AND date = ?", class.ID, DateOf(date)).First(&sessionPrice).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return class.Price, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionPrice.Price, nil
+}
+
+// Get session date from request and check the class is held on it
+func getSessionDateFromReq(w http.ResponseWriter, r *http.Request, class *Class) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", mux.Vars(r)["date"])
+	if err != nil {
+		log.Warn("Invalid session date in request: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid session date, expected YYYY-MM-DD")
+		return date, err
+	}
+
+	calendar, err := GetCalendar(db.DB, class.ID, date, date)
+	if err != nil {
+		log.Error("Error fetching class calendar from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return date, err
+	}
+
+	err = class.CheckDate(date, calendar)
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return date, err
+	}
+
+	return date, nil
+}
+
+func getSessionPrices(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var prices []SessionPrice
+	err = db.Where("class_id = ?", class.ID).Order("date ASC").Find(&prices).Error
+	if err != nil {
+		log.Error("Error fetching session prices from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&prices)
+}
+
+func setSessionPrice(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	date, err := getSessionDateFromReq(w, r, class)
+	if err != nil {
+		return
+	}
+
+	var payload struct {
+		Price *uint `json:"price"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil || payload.Price == nil {
+		log.Warn("Error parsing JSON when setting session price: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for session price, expected price")
+		return
+	}
+
+	sessionPrice := SessionPrice{ClassID: class.ID, Date: date, Price: *payload.Price}
+	err = db.Save(&sessionPrice).Error
+	if err != nil {
+		log.Error("Error saving session price to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&sessionPrice)
+}
+
+func deleteSessionPrice(w http.ResponseWriter, r *http.Request) {
+	class, err := db.getClassFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", mux.Vars(r)["date"])
+	if err != nil {
+		log.Warn("Invalid session date in request: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid session date, expected YYYY-MM-DD")
+		return
+	}
+
+	err = db.Where("class_id = ? AND date = ?", class.ID, date).Delete(&SessionPrice{}).Error
+	if err != nil {
+		log.Error("Error deleting session price from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Session price removed, the class price applies")
+}
@@ -0,0 +1,96 @@
+package classes
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func TestPriceOn(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_session_prices"  WHERE (class_id = 1 AND date = 2019-08-11`).WithReply([]map[string]interface{}{{
+		"class_id": 1,
+		"date":     time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"price":    2000,
+	}})
+
+	classPrice := uint(1500)
+	class := Class{ID: 1, Price: &classPrice}
+
+	price, err := PriceOn(db.DB, &class, time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil || price == nil || *price != 2000 {
+		t.Error("Expected the session price to apply, got:", price, err)
+	}
+
+	price, err = PriceOn(db.DB, &class, time.Date(2019, 8, 18, 0, 0, 0, 0, time.UTC))
+	if err != nil || price == nil || *price != 1500 {
+		t.Error("Expected the class price to apply, got:", price, err)
+	}
+
+	class.Price = nil
+	price, err = PriceOn(db.DB, &class, time.Date(2019, 8, 18, 0, 0, 0, 0, time.UTC))
+	if err != nil || price != nil {
+		t.Error("Expected session without a price not to be sold on its own, got:", price, err)
+	}
+}
+
+func TestSetSessionPrice(t *testing.T) {
+	mocket.Catcher.Reset()
+	setSundayClassMatch()
+	var saved []driver.NamedValue
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "class_session_prices"`).WithCallback(func(query string, args []driver.NamedValue) {
+		saved = args
+	}).WithRowsNum(1)
+
+	w, r := makePriceRequest(`{"price": 2000}`, map[string]string{"id": "1", "date": "2019-08-11"})
+	setSessionPrice(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+	if len(saved) == 0 || saved[0].Value != int64(2000) {
+		t.Error("Expected session price to be saved, got:", saved)
+	}
+}
+
+func TestSetSessionPriceNotHeld(t *testing.T) {
+	mocket.Catcher.Reset()
+	setSundayClassMatch()
+
+	// 2019-08-12 is a Monday, the class is held on Sundays
+	w, r := makePriceRequest(`{"price": 2000}`, map[string]string{"id": "1", "date": "2019-08-12"})
+	setSessionPrice(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+// Mock class 1 held on Sundays in August 2019
+func setSundayClassMatch() {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1)`).WithReply([]map[string]interface{}{{
+		"id":         1,
+		"name":       "Class #1",
+		"start_date": time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+		"weekdays":   "SU",
+	}})
+}
+
+func makePriceRequest(body string, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	r := httptest.NewRequest("PUT", "/classes/1/sessions/"+vars["date"]+"/price", strings.NewReader(body))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,102 @@
+package classes
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	"github.com/teeaa/studio/internal/rooms"
+)
+
+// ErrNoSuchRoom is returned when placing a class in a room which doesn't exist
+var ErrNoSuchRoom = errors.New("No such room")
+
+// Load capacities of the rooms classes are held in, in one query. Run on tx as it is, so
+// that the rooms aren't locked along with the classes.
+func loadRoomCapacities(tx *gorm.DB, classes []Class) error {
+	roomIDs := []uint64{}
+	for i := range classes {
+		classes[i].roomCapacity = nil
+		if classes[i].RoomID != nil {
+			roomIDs = append(roomIDs, *classes[i].RoomID)
+		}
+	}
+
+	if len(roomIDs) == 0 {
+		return nil
+	}
+
+	var found []rooms.Room
+	err := tx.Where("id IN (?)", roomIDs).Find(&found).Error
+	if err != nil {
+		return err
+	}
+
+	capacities := map[uint64]uint{}
+	for _, room := range found {
+		capacities[room.ID] = room.Capacity
+	}
+	for i := range classes {
+		if classes[i].RoomID == nil {
+			continue
+		}
+		if capacity, ok := capacities[*classes[i].RoomID]; ok {
+			classes[i].roomCapacity = &capacity
+		}
+	}
+
+	return nil
+}
+
+// EffectiveCapacity how many can book a session of the class, the smaller of
+// the class capacity and the capacity of its room. The room capacity is only loaded
+// with classes got by id, locked or fetched with their details.
+func (c *Class) EffectiveCapacity() uint {
+	if c.roomCapacity != nil && *c.roomCapacity < c.Capacity {
+		return *c.roomCapacity
+	}
+	return c.Capacity
+}
+
+// Check the room of class exists and no other class is held in it at the same time.
+// Called in the transaction saving the class, and locks the room row until it ends so
+// that classes are placed in the same room one at a time.
+func checkRoom(tx *gorm.DB, class *Class) ([]Conflict, error) {
+	conflicts := []Conflict{}
+	if class.RoomID == nil {
+		class.roomCapacity = nil
+		return conflicts, nil
+	}
+
+	room, err := rooms.LockRoomByID(tx, *class.RoomID)
+	if gorm.IsRecordNotFoundError(err) {
+		return conflicts, ErrNoSuchRoom
+	}
+	if err != nil {
+		return conflicts, err
+	}
+	class.roomCapacity = &room.Capacity
+
+	var others []Class
+	err = tx.Where("room_id = ? AND id <> ? AND start_date <= ? AND end_date >= ?", room.ID, class.ID, class.EndDate, class.StartDate).
+		Find(&others).Error
+	if err != nil {
+		return conflicts, err
+	}
+
+	for i := range others {
+		date, overlaps, err := firstOverlapWith(tx, class, &others[i])
+		if err != nil {
+			return conflicts, err
+		}
+		if overlaps {
+			conflicts = append(conflicts, Conflict{
+				RoomID:    room.ID,
+				ClassID:   others[i].ID,
+				ClassName: others[i].Name,
+				Date:      date.Format("2006-01-02"),
+			})
+		}
+	}
+
+	return conflicts, nil
+}
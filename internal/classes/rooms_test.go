@@ -0,0 +1,125 @@
+package classes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func TestEffectiveCapacity(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"`).WithReply([]map[string]interface{}{{
+		"id":       1,
+		"name":     "Class #1",
+		"capacity": 20,
+		"room_id":  2,
+	}})
+	var roomQueries []string
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "rooms"  WHERE (id IN (2))`).WithReply([]map[string]interface{}{{
+		"id":       2,
+		"name":     "Small room",
+		"capacity": 12,
+	}}).WithCallback(func(query string, args []driver.NamedValue) {
+		roomQueries = append(roomQueries, query)
+	})
+
+	class, err := GetClassByID(1)
+	if err != nil {
+		t.Error("Error getting class by id:", err)
+	}
+	if class.EffectiveCapacity() != 12 {
+		t.Errorf("Expected room to limit capacity to 12, got %d instead", class.EffectiveCapacity())
+	}
+
+	class, err = LockClassByID(db.DB, 1)
+	if err != nil {
+		t.Error("Error locking class by id:", err)
+	}
+	if class.EffectiveCapacity() != 12 {
+		t.Errorf("Expected room to limit capacity of locked class to 12, got %d instead", class.EffectiveCapacity())
+	}
+	if len(roomQueries) != 2 || strings.Contains(roomQueries[1], "FOR UPDATE") {
+		t.Error("Expected the room to be read without locking it along with the class, got:", roomQueries)
+	}
+
+	class.Capacity = 8
+	if class.EffectiveCapacity() != 8 {
+		t.Errorf("Expected class capacity 8 under the room capacity to apply, got %d instead", class.EffectiveCapacity())
+	}
+}
+
+func TestAddClassRoomTaken(t *testing.T) {
+	roomID := uint64(2)
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "rooms"`).WithReply([]map[string]interface{}{{"id": 2, "name": "Small room", "capacity": 12}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE (room_id = 2`).WithReply([]map[string]interface{}{{
+		"id":         3,
+		"name":       "Ballet",
+		"start_date": time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"weekdays":   "TU",
+		"timezone":   "UTC",
+	}})
+
+	requestData := Class{
+		Name:      "Tango",
+		StartDate: time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  20,
+		Weekdays:  NewWeekdays(time.Tuesday),
+		StartTime: "18:00",
+		RoomID:    &roomID,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+
+	var response struct {
+		Conflicts []Conflict `json:"conflicts"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	compare := Conflict{RoomID: 2, ClassID: 3, ClassName: "Ballet", Date: "2019-08-06"}
+	if len(response.Conflicts) != 1 || response.Conflicts[0] != compare {
+		t.Error("Received conflicts didn't match expectations:", response.Conflicts)
+	}
+}
+
+func TestAddClassNoSuchRoom(t *testing.T) {
+	roomID := uint64(2)
+	mocket.Catcher.Reset()
+
+	requestData := Class{
+		Name:      "Tango",
+		StartDate: time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Capacity:  20,
+		RoomID:    &roomID,
+	}
+
+	w, r, _ := makeRequest(&requestData, nil)
+	addClass(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package classes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Weekdays set of days of the week a class is held on, empty meaning every day
+type Weekdays uint8
+
+// RFC 5545 BYDAY codes indexed by time.Weekday
+var weekdayCodes = [7]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// NewWeekdays set of given days
+func NewWeekdays(days ...time.Weekday) Weekdays {
+	var set Weekdays
+	for _, day := range days {
+		set |= 1 << uint(day)
+	}
+	return set
+}
+
+// Has tells if day is in the set
+func (d Weekdays) Has(day time.Weekday) bool {
+	return d == 0 || d&(1<<uint(day)) != 0
+}
+
+// Codes of the days in the set, starting from Monday
+func (d Weekdays) Codes() []string {
+	codes := []string{}
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		if d != 0 && d.Has(day) {
+			codes = append(codes, weekdayCodes[day])
+		}
+	}
+	return codes
+}
+
+func parseWeekdays(codes []string) (Weekdays, error) {
+	var set Weekdays
+	for _, code := range codes {
+		found := false
+		for day, dayCode := range weekdayCodes {
+			if strings.EqualFold(strings.TrimSpace(code), dayCode) {
+				set |= 1 << uint(day)
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("Invalid weekday %q, expected one of %s", code, strings.Join(weekdayCodes[:], ", "))
+		}
+	}
+	return set, nil
+}
+
+// MarshalJSON as a list of weekday codes
+func (d Weekdays) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Codes())
+}
+
+// UnmarshalJSON from a list of weekday codes
+func (d *Weekdays) UnmarshalJSON(data []byte) error {
+	var codes []string
+	err := json.Unmarshal(data, &codes)
+	if err != nil {
+		return errors.New("Invalid weekdays in payload, expected a list such as [\"MO\", \"WE\"]")
+	}
+
+	*d, err = parseWeekdays(codes)
+	return err
+}
+
+// Value to store weekdays as comma separated codes
+func (d Weekdays) Value() (driver.Value, error) {
+	return strings.Join(d.Codes(), ","), nil
+}
+
+// Scan weekdays from comma separated codes
+func (d *Weekdays) Scan(value interface{}) error {
+	var codes string
+	switch v := value.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case []byte:
+		codes = string(v)
+	case string:
+		codes = v
+	default:
+		return fmt.Errorf("Unable to scan weekdays from %T", value)
+	}
+
+	if codes == "" {
+		*d = 0
+		return nil
+	}
+
+	var err error
+	*d, err = parseWeekdays(strings.Split(codes, ","))
+	return err
+}
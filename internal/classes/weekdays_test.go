@@ -0,0 +1,66 @@
+package classes
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWeekdaysJSON(t *testing.T) {
+	var weekdays Weekdays
+	err := json.Unmarshal([]byte(`["we", "MO"]`), &weekdays)
+	if err != nil {
+		t.Fatal("Error unmarshalling weekdays:", err)
+	}
+
+	if weekdays != NewWeekdays(time.Monday, time.Wednesday) {
+		t.Error("Unmarshalled weekdays didn't match expectations:", weekdays.Codes())
+	}
+
+	data, _ := json.Marshal(weekdays)
+	if string(data) != `["MO","WE"]` {
+		t.Error("Marshalled weekdays didn't match expectations:", string(data))
+	}
+
+	err = json.Unmarshal([]byte(`["Monday"]`), &weekdays)
+	if err == nil {
+		t.Error("Expected unknown weekday code to fail")
+	}
+}
+
+func TestWeekdaysDatabase(t *testing.T) {
+	weekdays := NewWeekdays(time.Sunday, time.Friday)
+	value, _ := weekdays.Value()
+	if value != "FR,SU" {
+		t.Error("Stored weekdays didn't match expectations:", value)
+	}
+
+	var scanned Weekdays
+	err := scanned.Scan([]byte("FR,SU"))
+	if err != nil || scanned != weekdays {
+		t.Error("Scanned weekdays didn't match expectations:", scanned.Codes(), err)
+	}
+
+	err = scanned.Scan(nil)
+	if err != nil || scanned != 0 || !scanned.Has(time.Tuesday) {
+		t.Error("Expected empty weekdays to mean every day")
+	}
+}
+
+func TestClassMeetsOn(t *testing.T) {
+	class := Class{
+		StartDate: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		Weekdays:  NewWeekdays(time.Tuesday),
+	}
+
+	if !class.MeetsOn(time.Date(2019, 8, 27, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected class to be held on a Tuesday")
+	}
+	if class.MeetsOn(time.Date(2019, 8, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected class not to be held on a Wednesday")
+	}
+	if class.MeetsOn(time.Date(2019, 9, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected class not to be held after its end date")
+	}
+}
@@ -0,0 +1,18 @@
+package helpers
+
+import (
+	"github.com/go-sql-driver/mysql"
+)
+
+/***
+ * Database helpers
+ ***/
+
+// MySQL error number for a violated unique index
+const mysqlDuplicateEntry = 1062
+
+// IsDuplicateEntry tells if err is caused by a violated unique index
+func IsDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlDuplicateEntry
+}
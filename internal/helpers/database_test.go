@@ -0,0 +1,20 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsDuplicateEntry(t *testing.T) {
+	if !IsDuplicateEntry(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}) {
+		t.Error("Duplicate entry error wasn't recognised")
+	}
+	if IsDuplicateEntry(&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}) {
+		t.Error("Other MySQL error was taken for a duplicate entry")
+	}
+	if IsDuplicateEntry(errors.New("Duplicate entry")) || IsDuplicateEntry(nil) {
+		t.Error("Non-MySQL error was taken for a duplicate entry")
+	}
+}
@@ -0,0 +1,72 @@
+package instructors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// ErrHasClasses is returned when removing an instructor who still teaches classes
+var ErrHasClasses = errors.New("Instructor teaches classes and can't be removed")
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// LockInstructorsByIDs get instructors inside transaction tx and lock their rows until the
+// transaction ends, so that their classes are assigned one at a time
+func LockInstructorsByIDs(tx *gorm.DB, instructorIDs []uint64) ([]Instructor, error) {
+	instructors := []Instructor{}
+	if len(instructorIDs) == 0 {
+		return instructors, nil
+	}
+
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id IN (?)", instructorIDs).Find(&instructors).Error
+	return instructors, err
+}
+
+// Count classes instructor teaches. Classes build on instructors, so the
+// assignments are counted straight from their table.
+func (db *Database) countClasses(instructorID uint64) (uint, error) {
+	var count uint
+	err := db.Table("class_instructors").Where("instructor_id = ?", instructorID).Count(&count).Error
+	return count, err
+}
+
+// GetInstructorFromReq get instructor from database by id in request and handle error situations
+func GetInstructorFromReq(w http.ResponseWriter, r *http.Request) (*Instructor, error) {
+	var instructor Instructor
+	vars := mux.Vars(r)
+	instructorID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested instructor id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid instructor ID")
+		return nil, err
+	}
+
+	err = db.First(&instructor, instructorID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested instructor by id %d does not exist", instructorID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Instructor does not exist")
+		} else {
+			log.Error("Error fetching instructor from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &instructor, nil
+}
@@ -0,0 +1,114 @@
+package instructors
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getInstructors(w http.ResponseWriter, r *http.Request) {
+	var instructors []Instructor
+	err := db.Find(&instructors).Error
+
+	if err != nil {
+		log.Error("Error fetching instructors from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&instructors)
+}
+
+func addInstructor(w http.ResponseWriter, r *http.Request) {
+	var instructor Instructor
+	err := json.NewDecoder(r.Body).Decode(&instructor)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new instructor: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for instructor")
+		return
+	}
+
+	err = db.Create(&instructor).Error
+	if err != nil {
+		log.Error("Error inserting instructor to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&instructor)
+}
+
+func getInstructor(w http.ResponseWriter, r *http.Request) {
+	instructor, err := GetInstructorFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&instructor)
+}
+
+func updateInstructor(w http.ResponseWriter, r *http.Request) {
+	instructor, err := GetInstructorFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&instructor)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating instructor: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for instructor")
+		return
+	}
+
+	err = db.Save(&instructor).Error
+	if err != nil {
+		log.Error("Error saving instructor to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&instructor)
+}
+
+func deleteInstructor(w http.ResponseWriter, r *http.Request) {
+	instructor, err := GetInstructorFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	classes, err := db.countClasses(instructor.ID)
+	if err != nil {
+		log.Error("Error counting classes of instructor from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if classes > 0 {
+		helpers.ResponseJSON(w, http.StatusConflict, ErrHasClasses.Error())
+		return
+	}
+
+	err = db.Delete(&instructor).Error
+	if err != nil {
+		log.Error("Error deleting instructor from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Instructor removed")
+}
+
+// Routes set routes for /instructors
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Instructor{})
+
+	router.HandleFunc("", getInstructors).Methods("GET")
+	router.HandleFunc("", addInstructor).Methods("POST")
+	router.HandleFunc("/{id}", getInstructor).Methods("GET")
+	router.HandleFunc("/{id}", updateInstructor).Methods("PUT")
+	router.HandleFunc("/{id}", deleteInstructor).Methods("DELETE")
+}
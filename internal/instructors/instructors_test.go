@@ -0,0 +1,118 @@
+package instructors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+func TestAddInstructor(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset().NewMock().WithQuery(`INSERT  INTO "instructors"`).WithID(2)
+
+	w, r := makeRequest(map[string]interface{}{"id": 7, "name": " Anna ", "email": "Anna@Example.com"}, nil)
+	addInstructor(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var instructor map[string]interface{}
+	err = json.Unmarshal(body, &instructor)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if instructor["id"] != float64(2) || instructor["name"] != "Anna" || instructor["email"] != "anna@example.com" {
+		t.Error("Received instructor didn't match expectations:", instructor)
+	}
+}
+
+func TestAddInstructorWithoutName(t *testing.T) {
+	w, r := makeRequest(map[string]interface{}{"email": "nameless@example.com"}, nil)
+	addInstructor(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestGetInstructor(t *testing.T) {
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "instructors"  WHERE ("instructors"."id" = 2)`).WithReply([]map[string]interface{}{{
+		"id":   2,
+		"name": "Anna",
+	}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	getInstructor(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var instructor Instructor
+	err = json.Unmarshal(body, &instructor)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if instructor.Name != "Anna" {
+		t.Error("Received instructor didn't match expectations:", instructor)
+	}
+}
+
+func TestDeleteInstructorWithClasses(t *testing.T) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "class_instructors"`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	deleteInstructor(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestDeleteInstructorNonExisting(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "345"})
+	deleteInstructor(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/instructors", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
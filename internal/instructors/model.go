@@ -0,0 +1,40 @@
+package instructors
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Instructor representation of instructors.instructors
+type Instructor struct {
+	ID    uint64 `gorm:"primary_key" json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// UnmarshalJSON to normalise contact details and strip ID from requests
+func (i *Instructor) UnmarshalJSON(data []byte) error {
+	type Alias Instructor
+	aux := &struct {
+		ID uint64 `gorm:"-" sql:"-" json:"id"`
+		*Alias
+	}{
+		Alias: (*Alias)(i),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	i.Name = strings.TrimSpace(i.Name)
+	if i.Name == "" {
+		return errors.New("Missing name in payload")
+	}
+	i.Email = strings.ToLower(strings.TrimSpace(i.Email))
+	i.Phone = strings.TrimSpace(i.Phone)
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package invoices
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+var studioVATRate float64
+var studioCurrency = "EUR"
+
+// SetVATRate set VAT percentage applied to prices which don't define their own
+func SetVATRate(rate float64) error {
+	if rate < 0 || rate >= 100 {
+		return errors.New("VAT rate must be a percentage from 0 to 100")
+	}
+
+	studioVATRate = rate
+	return nil
+}
+
+// VATRate VAT percentage applied to prices which don't define their own
+func VATRate() float64 {
+	return studioVATRate
+}
+
+// SetCurrency set ISO 4217 code of the currency invoices are issued in
+func SetCurrency(code string) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return errors.New("Currency must be a three letter ISO 4217 code")
+	}
+
+	studioCurrency = code
+	return nil
+}
+
+// Id of the single row of the invoice sequence
+const sequenceID = 1
+
+// Create the invoice sequence unless it exists, continuing from the latest invoice issued before it
+func (db *Database) createSequence() error {
+	return db.Exec("INSERT IGNORE INTO `invoice_sequence` (`id`, `last_number`) SELECT ?, COALESCE(MAX(`number`), 0) FROM `invoices`", sequenceID).Error
+}
+
+// Issue number and insert invoice with its lines inside transaction tx. The invoice sequence
+// is locked while numbering, so that concurrent invoices get consecutive numbers.
+func Issue(tx *gorm.DB, invoice *Invoice) error {
+	if len(invoice.Lines) == 0 {
+		return ErrNoLines
+	}
+
+	var member members.Member
+	err := tx.First(&member, invoice.MemberID).Error
+	if err != nil {
+		return err
+	}
+
+	var sequence Sequence
+	err = tx.Set("gorm:query_option", "FOR UPDATE").First(&sequence, sequenceID).Error
+	if err != nil {
+		return err
+	}
+
+	sequence.LastNumber++
+	err = tx.Model(&sequence).Update("last_number", sequence.LastNumber).Error
+	if err != nil {
+		return err
+	}
+
+	invoice.Number = sequence.LastNumber
+	invoice.Recipient = member.Name
+	invoice.IssuedAt = time.Now().UTC()
+	invoice.Currency = studioCurrency
+	invoice.sum()
+
+	err = tx.Create(invoice).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range invoice.Lines {
+		invoice.Lines[i].InvoiceID = invoice.ID
+		err = tx.Create(&invoice.Lines[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load line items of invoices
+func loadLines(tx *gorm.DB, invoices []Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	invoiceIDs := make([]uint64, len(invoices))
+	for i := range invoices {
+		invoiceIDs[i] = invoices[i].ID
+		invoices[i].Lines = []Line{}
+	}
+
+	var lines []Line
+	err := tx.Where("invoice_id IN (?)", invoiceIDs).Order("id ASC").Find(&lines).Error
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		for i := range invoices {
+			if invoices[i].ID == line.InvoiceID {
+				invoices[i].Lines = append(invoices[i].Lines, line)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Get invoice with its lines from database by id in request and handle error situations
+func (db *Database) getInvoiceFromReq(w http.ResponseWriter, r *http.Request) (*Invoice, error) {
+	var invoice Invoice
+	vars := mux.Vars(r)
+	invoiceID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested invoice id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid invoice ID")
+		return nil, err
+	}
+
+	err = db.First(&invoice, invoiceID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested invoice by id %d does not exist", invoiceID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Invoice does not exist")
+		} else {
+			log.Error("Error fetching invoice from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+
+	invoices := []Invoice{invoice}
+	err = loadLines(db.DB, invoices)
+	if err != nil {
+		log.Error("Error fetching lines of invoice from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return nil, err
+	}
+	return &invoices[0], nil
+}
@@ -0,0 +1,85 @@
+package invoices
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Printable invoice, styled to fit on a single A4 page
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"money": money,
+	"rate":  rate,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-top: 1.5em; }
+th, td { padding: 0.4em; border-bottom: 1px solid #ccc; text-align: left; }
+td.amount, th.amount { text-align: right; }
+tfoot td { font-weight: bold; border-bottom: none; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>
+Date: {{.IssuedAt.Format "2006-01-02"}}<br>
+Bill to: {{.Recipient}} (member {{.MemberID}})
+</p>
+<table>
+<thead>
+<tr><th>Description</th><th class="amount">Quantity</th><th class="amount">Unit price</th><th class="amount">Discount</th><th class="amount">VAT %</th><th class="amount">Net</th><th class="amount">VAT</th><th class="amount">Total</th></tr>
+</thead>
+<tbody>
+{{- range .Lines}}
+<tr><td>{{.Description}}</td><td class="amount">{{.Quantity}}</td><td class="amount">{{money .UnitPrice}}</td><td class="amount">{{if .Discount}}-{{money .Discount}}{{end}}</td><td class="amount">{{rate .VATRate}}</td><td class="amount">{{money .Net}}</td><td class="amount">{{money .VAT}}</td><td class="amount">{{money .Total}}</td></tr>
+{{- end}}
+</tbody>
+<tfoot>
+<tr><td colspan="5">Total {{.Currency}}</td><td class="amount">{{money .Net}}</td><td class="amount">{{money .VAT}}</td><td class="amount">{{money .Total}}</td></tr>
+</tfoot>
+</table>
+<table>
+<thead>
+<tr><th>VAT %</th><th class="amount">Net</th><th class="amount">VAT</th></tr>
+</thead>
+<tbody>
+{{- range .VATByRate}}
+<tr><td>{{rate .Rate}}</td><td class="amount">{{money .Net}}</td><td class="amount">{{money .VAT}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// Format amount in cents with two decimals
+func money(cents uint) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// Format VAT percentage without trailing zeros
+func rate(percent float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", percent), "0"), ".")
+}
+
+// Tells if the request asks for the printable HTML invoice instead of JSON
+func wantsHTML(r *http.Request) bool {
+	format := r.URL.Query().Get("format")
+	if format != "" {
+		return format == "html"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// Write printable HTML invoice
+func writeHTML(w http.ResponseWriter, invoice *Invoice) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return invoiceTemplate.Execute(w, invoice)
+}
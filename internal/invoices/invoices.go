@@ -0,0 +1,86 @@
+package invoices
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+func getInvoices(w http.ResponseWriter, r *http.Request) {
+	var invoices []Invoice
+	err := db.Order("number DESC").Find(&invoices).Error
+	if err == nil {
+		err = loadLines(db.DB, invoices)
+	}
+
+	if err != nil {
+		log.Error("Error fetching invoices from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&invoices)
+}
+
+func getInvoice(w http.ResponseWriter, r *http.Request) {
+	invoice, err := db.getInvoiceFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	if wantsHTML(r) {
+		err = writeHTML(w, invoice)
+		if err != nil {
+			log.Error("Error rendering invoice: ", err)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(&invoice)
+}
+
+func getMemberInvoices(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var invoices []Invoice
+	err = db.Where("member_id = ?", member.ID).Order("number DESC").Find(&invoices).Error
+	if err == nil {
+		err = loadLines(db.DB, invoices)
+	}
+
+	if err != nil {
+		log.Error("Error fetching invoices of member from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&invoices)
+}
+
+// MemberRoutes set routes for invoices under /members
+func MemberRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/invoices", getMemberInvoices).Methods("GET")
+}
+
+// Routes set routes for /invoices
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Invoice{}, &Line{}, &Sequence{})
+	err := db.createSequence()
+	if err != nil {
+		log.Error("Error creating invoice sequence: ", err)
+	}
+
+	router.HandleFunc("", getInvoices).Methods("GET")
+	router.HandleFunc("/{id}", getInvoice).Methods("GET")
+}
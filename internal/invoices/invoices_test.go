@@ -0,0 +1,175 @@
+package invoices
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+// Mock invoice 3 with a line at the reduced and at the standard VAT rate
+func setInvoiceMatch() {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "invoices"  WHERE ("invoices"."id" = 3)`).WithReply([]map[string]interface{}{{
+		"id":        3,
+		"number":    12,
+		"member_id": 5,
+		"recipient": "Tester <script>",
+		"issued_at": time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC),
+		"currency":  "EUR",
+		"net":       3000,
+		"vat":       620,
+		"total":     3620,
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "invoice_lines"`).WithReply([]map[string]interface{}{
+		{"id": 1, "invoice_id": 3, "description": "Ballet on 2019-08-11", "quantity": 1, "unit_price": 1100, "vat_rate": 10, "net": 1000, "vat": 100, "total": 1100},
+		{"id": 2, "invoice_id": 3, "description": "Leotard", "quantity": 2, "unit_price": 1260, "vat_rate": 26, "net": 2000, "vat": 520, "total": 2520},
+	})
+}
+
+func TestSum(t *testing.T) {
+	invoice := Invoice{Lines: []Line{
+		{Quantity: 1, UnitPrice: 1500, VATRate: 10},
+		{Quantity: 3, UnitPrice: 999, VATRate: 25.5},
+		{Quantity: 1, UnitPrice: 800, VATRate: 10},
+	}}
+	invoice.sum()
+
+	line := invoice.Lines[1]
+	if line.Total != 2997 || line.VAT != 609 || line.Net != 2388 {
+		t.Error("Line totals didn't match expectations:", line)
+	}
+	if invoice.Total != 5297 || invoice.VAT != 818 || invoice.Net != 4479 {
+		t.Error("Invoice totals didn't match expectations:", invoice)
+	}
+
+	summaries := invoice.VATByRate()
+	if len(summaries) != 2 || summaries[0].Rate != 10 || summaries[0].VAT != 209 || summaries[1].VAT != 609 {
+		t.Error("VAT by rate didn't match expectations:", summaries)
+	}
+}
+
+func TestIssueFirstInvoice(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"`).WithReply([]map[string]interface{}{{"id": 5, "name": "Tester"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "invoice_sequence"  WHERE ("invoice_sequence"."id" = 1)`).WithReply([]map[string]interface{}{{"id": 1, "last_number": 0}})
+	var sequenced interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "invoice_sequence"`).WithCallback(func(query string, args []driver.NamedValue) {
+		sequenced = args[0].Value
+	}).WithRowsNum(1)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "invoices"`).WithID(1)
+
+	invoice := Invoice{MemberID: 5, Lines: []Line{{Description: "Ballet", Quantity: 1, UnitPrice: 1500}}}
+	err := Issue(db.DB, &invoice)
+	if err != nil {
+		t.Error("Error issuing invoice:", err)
+	}
+
+	if invoice.Number != 1 || invoice.Recipient != "Tester" || invoice.Currency != "EUR" || invoice.Total != 1500 {
+		t.Error("Issued invoice didn't match expectations:", invoice)
+	}
+	if invoice.Lines[0].InvoiceID != 1 {
+		t.Error("Expected lines to be linked to the invoice:", invoice.Lines)
+	}
+	if sequenced != int64(1) {
+		t.Error("Expected the sequence to be moved on to the issued number, got:", sequenced)
+	}
+}
+
+func TestIssueWithoutLines(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	err := Issue(db.DB, &Invoice{MemberID: 5})
+	if err != ErrNoLines {
+		t.Error("Expected issuing an invoice without lines to fail, got:", err)
+	}
+}
+
+func TestGetInvoice(t *testing.T) {
+	mocket.Catcher.Reset()
+	setInvoiceMatch()
+
+	w, r := makeRequest("/invoices/3", map[string]string{"id": "3"})
+	getInvoice(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var invoice Invoice
+	err = json.Unmarshal(body, &invoice)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if invoice.Number != 12 || len(invoice.Lines) != 2 || invoice.Lines[1].VATRate != 26 {
+		t.Error("Received invoice didn't match expectations:", invoice)
+	}
+}
+
+func TestGetInvoiceHTML(t *testing.T) {
+	mocket.Catcher.Reset()
+	setInvoiceMatch()
+
+	w, r := makeRequest("/invoices/3", map[string]string{"id": "3"})
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	getInvoice(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Error("Expected HTML content type, got:", w.Header().Get("Content-Type"))
+	}
+
+	page := string(body)
+	for _, expected := range []string{"Invoice 12", "Leotard", "25.20", "36.20", "Tester &lt;script&gt;"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected invoice page to contain %q:\n%s", expected, page)
+		}
+	}
+}
+
+func TestGetInvoiceNonExisting(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	w, r := makeRequest("/invoices/4?format=html", map[string]string{"id": "4"})
+	getInvoice(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(target string, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	r := httptest.NewRequest("GET", target, nil)
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
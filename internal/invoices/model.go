@@ -0,0 +1,120 @@
+package invoices
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+)
+
+// Invoice representation of invoices.invoices. Amounts are in cents. Invoices are numbered
+// sequentially when issued and never change afterwards, so the name of the member is
+// stored as it was on the issue date.
+type Invoice struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	Number    uint64    `gorm:"unique_index" json:"number"`
+	MemberID  uint64    `json:"member_id"`
+	Recipient string    `json:"recipient"`
+	IssuedAt  time.Time `json:"issued_at"`
+	Currency  string    `gorm:"type:char(3)" json:"currency"`
+	Net       uint      `json:"net"`
+	VAT       uint      `json:"vat"`
+	Total     uint      `json:"total"`
+	// Line items of the invoice, stored in invoice_lines
+	Lines []Line `gorm:"-" json:"lines"`
+}
+
+// Line item of an invoice. UnitPrice includes VAT, which is worked out of the line total
+// after Discount is taken off.
+type Line struct {
+	ID          uint64  `gorm:"primary_key" json:"id"`
+	InvoiceID   uint64  `json:"invoice_id"`
+	Description string  `json:"description"`
+	Quantity    uint    `json:"quantity"`
+	UnitPrice   uint    `json:"unit_price"`
+	Discount    uint    `json:"discount"`
+	VATRate     float64 `json:"vat_rate"`
+	Net         uint    `json:"net"`
+	VAT         uint    `json:"vat"`
+	Total       uint    `json:"total"`
+}
+
+// TableName to keep invoice lines next to invoices
+func (Line) TableName() string {
+	return "invoice_lines"
+}
+
+// Sequence representation of invoices.invoice_sequence, the single row holding the number of the
+// latest invoice. It's locked while numbering, so that concurrent invoices get consecutive numbers.
+type Sequence struct {
+	ID         uint64 `gorm:"primary_key"`
+	LastNumber uint64
+}
+
+// TableName to keep the sequence next to invoices
+func (Sequence) TableName() string {
+	return "invoice_sequence"
+}
+
+// ErrNoLines is returned when issuing an invoice without line items
+var ErrNoLines = errors.New("Invoice has no lines")
+
+// Work out the discounted total of the line and the VAT included in it, rounded to the nearest cent
+func (l *Line) sum() {
+	l.Total = l.Quantity * l.UnitPrice
+	if l.Discount > l.Total {
+		l.Discount = l.Total
+	}
+	l.Total -= l.Discount
+	l.VAT = uint(math.Round(float64(l.Total) * l.VATRate / (100 + l.VATRate)))
+	l.Net = l.Total - l.VAT
+}
+
+// Work out totals of the invoice from its lines
+func (i *Invoice) sum() {
+	i.Net, i.VAT, i.Total = 0, 0, 0
+	for j := range i.Lines {
+		i.Lines[j].sum()
+		i.Net += i.Lines[j].Net
+		i.VAT += i.Lines[j].VAT
+		i.Total += i.Lines[j].Total
+	}
+}
+
+// VATSummary VAT of an invoice grouped by rate
+type VATSummary struct {
+	Rate float64 `json:"rate"`
+	Net  uint    `json:"net"`
+	VAT  uint    `json:"vat"`
+}
+
+// VATByRate VAT of the invoice lines grouped by rate, in the order the rates first appear
+func (i *Invoice) VATByRate() []VATSummary {
+	summaries := []VATSummary{}
+	for _, line := range i.Lines {
+		found := false
+		for j := range summaries {
+			if summaries[j].Rate == line.VATRate {
+				summaries[j].Net += line.Net
+				summaries[j].VAT += line.VAT
+				found = true
+			}
+		}
+		if !found {
+			summaries = append(summaries, VATSummary{Rate: line.VATRate, Net: line.Net, VAT: line.VAT})
+		}
+	}
+	return summaries
+}
+
+// MarshalJSON to include the VAT breakdown by rate
+func (i *Invoice) MarshalJSON() ([]byte, error) {
+	type Alias Invoice
+	return json.Marshal(&struct {
+		VATByRate []VATSummary `json:"vat_by_rate"`
+		*Alias
+	}{
+		VATByRate: i.VATByRate(),
+		Alias:     (*Alias)(i),
+	})
+}
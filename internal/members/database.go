@@ -0,0 +1,107 @@
+package members
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// ErrEmailTaken is returned when another member already has the email
+var ErrEmailTaken = errors.New("Email is already used by another member")
+
+// ErrHasBookings is returned when removing a member whose booking history would be lost
+var ErrHasBookings = errors.New("Member has bookings and can't be removed")
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// GetMemberByID get member from member db
+func GetMemberByID(memberID uint64) (Member, error) {
+	var member Member
+	err := db.First(&member, memberID).Error
+
+	if err != nil {
+		log.Error("Error retrieving member from db:", err)
+		return Member{}, err
+	}
+
+	return member, nil
+}
+
+// GetMembersByIDs get members with the given ids from member db
+func GetMembersByIDs(memberIDs []uint64) ([]Member, error) {
+	members := []Member{}
+	if len(memberIDs) == 0 {
+		return members, nil
+	}
+
+	err := db.Where("id IN (?)", memberIDs).Find(&members).Error
+	return members, err
+}
+
+// Insert or update member, keeping emails unique. The unique index on email is the
+// final guard, checking first only gives a clearer error in the common case.
+func (db *Database) saveMember(member *Member) error {
+	if member.Email != nil {
+		var taken uint
+		err := db.Model(&Member{}).Where("email = ? AND id <> ?", *member.Email, member.ID).Count(&taken).Error
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailTaken
+		}
+	}
+
+	err := db.Save(member).Error
+	if helpers.IsDuplicateEntry(err) {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// Count bookings of member. Bookings build on members, so they're counted straight from their table.
+func (db *Database) countBookings(memberID uint64) (uint, error) {
+	var count uint
+	err := db.Table("bookings").Where("member_id = ?", memberID).Count(&count).Error
+	return count, err
+}
+
+// GetMemberFromReq get member from database by id in request and handle error situations
+func GetMemberFromReq(w http.ResponseWriter, r *http.Request) (*Member, error) {
+	var member Member
+	vars := mux.Vars(r)
+	memberID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested member id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid member ID")
+		return nil, err
+	}
+
+	err = db.First(&member, memberID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested member by id %d does not exist", memberID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Member does not exist")
+		} else {
+			log.Error("Error fetching member from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &member, nil
+}
@@ -0,0 +1,122 @@
+package members
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getMembers(w http.ResponseWriter, r *http.Request) {
+	var members []Member
+	err := db.Find(&members).Error
+
+	if err != nil {
+		log.Error("Error fetching members from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&members)
+}
+
+func addMember(w http.ResponseWriter, r *http.Request) {
+	var member Member
+	err := json.NewDecoder(r.Body).Decode(&member)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new member: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for member")
+		return
+	}
+
+	err = db.saveMember(&member)
+	if err == ErrEmailTaken {
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error inserting member to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&member)
+}
+
+func getMember(w http.ResponseWriter, r *http.Request) {
+	member, err := GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&member)
+}
+
+func updateMember(w http.ResponseWriter, r *http.Request) {
+	member, err := GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&member)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating member: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for member")
+		return
+	}
+
+	err = db.saveMember(member)
+	if err == ErrEmailTaken {
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error saving member to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&member)
+}
+
+func deleteMember(w http.ResponseWriter, r *http.Request) {
+	member, err := GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	booked, err := db.countBookings(member.ID)
+	if err != nil {
+		log.Error("Error counting bookings of member from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if booked > 0 {
+		helpers.ResponseJSON(w, http.StatusConflict, ErrHasBookings.Error())
+		return
+	}
+
+	err = db.Delete(&member).Error
+	if err != nil {
+		log.Error("Error deleting member from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Member removed")
+}
+
+// Routes set routes for /members
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Member{})
+
+	router.HandleFunc("", getMembers).Methods("GET")
+	router.HandleFunc("", addMember).Methods("POST")
+	router.HandleFunc("/{id}", getMember).Methods("GET")
+	router.HandleFunc("/{id}", updateMember).Methods("PUT")
+	router.HandleFunc("/{id}", deleteMember).Methods("DELETE")
+}
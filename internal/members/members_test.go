@@ -0,0 +1,146 @@
+package members
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+// Mock the amount of members already using an email
+func setEmailTaken(count int) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "members"`).WithReply([]map[string]interface{}{{"count(*)": count}})
+}
+
+func TestAddMember(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	setEmailTaken(0)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "members"`).WithID(4)
+
+	w, r := makeRequest(map[string]interface{}{"name": " Teea ", "email": " Teea@Example.com", "phone": "+358 40 123"}, nil)
+	addMember(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var member map[string]interface{}
+	err = json.Unmarshal(body, &member)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if member["id"] != float64(4) || member["name"] != "Teea" || member["email"] != "teea@example.com" || member["phone"] != "+358 40 123" {
+		t.Error("Received member didn't match expectations:", member)
+	}
+}
+
+func TestAddMemberEmailTaken(t *testing.T) {
+	mocket.Catcher.Reset()
+	setEmailTaken(1)
+
+	w, r := makeRequest(map[string]interface{}{"name": "Other Teea", "email": "teea@example.com"}, nil)
+	addMember(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestAddMemberInvalid(t *testing.T) {
+	for _, payload := range []map[string]interface{}{{"name": "  "}, {"name": "Teea", "email": "not an email"}} {
+		w, r := makeRequest(payload, nil)
+		addMember(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected HTTP status 400 for %v, got %d instead", payload, w.Code)
+		}
+	}
+}
+
+func TestGetMember(t *testing.T) {
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "members"  WHERE ("members"."id" = 4)`).WithReply([]map[string]interface{}{{
+		"id":    4,
+		"name":  "Teea",
+		"email": "teea@example.com",
+	}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "4"})
+	getMember(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var member Member
+	err = json.Unmarshal(body, &member)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if member.Name != "Teea" || member.Email == nil || *member.Email != "teea@example.com" {
+		t.Error("Received member didn't match expectations:", member)
+	}
+}
+
+func TestDeleteMemberWithBookings(t *testing.T) {
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "members"  WHERE ("members"."id" = 4)`).WithReply([]map[string]interface{}{{
+		"id":   4,
+		"name": "Teea",
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply([]map[string]interface{}{{"count(*)": 2}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "4"})
+	deleteMember(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestGetMemberNonExisting(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	w, r := makeRequest(nil, map[string]string{"id": "345"})
+	getMember(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/members", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
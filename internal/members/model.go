@@ -0,0 +1,53 @@
+package members
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Member representation of members.members
+type Member struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	Name      string    `json:"name"`
+	Email     *string   `gorm:"type:varchar(255);unique_index" json:"email"`
+	Phone     string    `json:"phone"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UnmarshalJSON to normalise contact details and strip ID and read-only fields from requests
+func (m *Member) UnmarshalJSON(data []byte) error {
+	type Alias Member
+	aux := &struct {
+		ID        uint64    `gorm:"-" sql:"-" json:"id"`
+		CreatedAt time.Time `gorm:"-" sql:"-" json:"created_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(m),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		return errors.New("Missing name in payload")
+	}
+
+	if m.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*m.Email))
+		if email == "" {
+			m.Email = nil
+		} else if !strings.Contains(email, "@") {
+			return errors.New("Invalid email in payload")
+		} else {
+			m.Email = &email
+		}
+	}
+	m.Phone = strings.TrimSpace(m.Phone)
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package memberships
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// ErrNoSuchStyle is returned when making a membership valid for a class style which doesn't exist
+var ErrNoSuchStyle = errors.New("No such style")
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// Covering active membership of member valid for a session of the class on date, inside
+// transaction tx. Returns nil when member has no such membership.
+func Covering(tx *gorm.DB, memberID uint64, classID uint64, date time.Time) (*Membership, error) {
+	var membership Membership
+	err := tx.Where("member_id = ? AND status = ? AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)", memberID, StatusActive, date, date).
+		Where("id NOT IN (SELECT membership_id FROM membership_styles) OR "+
+			"id IN (SELECT membership_styles.membership_id FROM membership_styles "+
+			"JOIN classes ON classes.style_id = membership_styles.style_id WHERE classes.id = ?)", classID).
+		Order("id ASC").
+		First(&membership).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// Load names of the styles memberships are valid for
+func loadStyles(tx *gorm.DB, memberships []Membership) error {
+	if len(memberships) == 0 {
+		return nil
+	}
+
+	membershipIDs := make([]uint64, len(memberships))
+	for i := range memberships {
+		membershipIDs[i] = memberships[i].ID
+		memberships[i].Styles = []string{}
+	}
+
+	var styles []struct {
+		MembershipID uint64
+		Name         string
+	}
+	err := tx.Table("membership_styles").
+		Select("membership_styles.membership_id, styles.name").
+		Joins("JOIN styles ON styles.id = membership_styles.style_id").
+		Where("membership_styles.membership_id IN (?)", membershipIDs).
+		Order("styles.name ASC").
+		Scan(&styles).Error
+	if err != nil {
+		return err
+	}
+
+	for _, style := range styles {
+		for i := range memberships {
+			if memberships[i].ID == style.MembershipID {
+				memberships[i].Styles = append(memberships[i].Styles, style.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Insert membership with the styles it's valid for in one transaction
+func (db *Database) addMembership(membership *Membership) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	var styles []classes.Style
+	if len(membership.Styles) > 0 {
+		err := tx.Where("name IN (?)", membership.Styles).Find(&styles).Error
+		if err != nil {
+			return err
+		}
+		if len(styles) != len(membership.Styles) {
+			return ErrNoSuchStyle
+		}
+	}
+
+	err := tx.Create(membership).Error
+	if err != nil {
+		return err
+	}
+
+	for _, style := range styles {
+		err = tx.Create(&MembershipStyle{MembershipID: membership.ID, StyleID: style.ID}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Move membership to status in a transaction which locks its row, checking the status
+// against the membership as stored once locked
+func (db *Database) transition(membership *Membership, status string) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	err := tx.Set("gorm:query_option", "FOR UPDATE").First(membership, membership.ID).Error
+	if err != nil {
+		return err
+	}
+
+	err = membership.Transition(status, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	err = tx.Save(membership).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Get membership from database by id in request and handle error situations
+func (db *Database) getMembershipFromReq(w http.ResponseWriter, r *http.Request) (*Membership, error) {
+	var membership Membership
+	vars := mux.Vars(r)
+	membershipID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested membership id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid membership ID")
+		return nil, err
+	}
+
+	err = db.First(&membership, membershipID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested membership by id %d does not exist", membershipID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Membership does not exist")
+		} else {
+			log.Error("Error fetching membership from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+
+	memberships := []Membership{membership}
+	err = loadStyles(db.DB, memberships)
+	if err != nil {
+		log.Error("Error fetching styles of membership from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return nil, err
+	}
+	return &memberships[0], nil
+}
@@ -0,0 +1,112 @@
+package memberships
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+func getMemberMemberships(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var memberships []Membership
+	err = db.Where("member_id = ?", member.ID).Order("start_date ASC").Find(&memberships).Error
+	if err == nil {
+		err = loadStyles(db.DB, memberships)
+	}
+	if err != nil {
+		log.Error("Error fetching memberships of member from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&memberships)
+}
+
+func addMemberMembership(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var membership Membership
+	err = json.NewDecoder(r.Body).Decode(&membership)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new membership: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for membership")
+		return
+	}
+	membership.MemberID = member.ID
+
+	err = db.addMembership(&membership)
+	if err == ErrNoSuchStyle {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error inserting membership to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&membership)
+}
+
+func getMembership(w http.ResponseWriter, r *http.Request) {
+	membership, err := db.getMembershipFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&membership)
+}
+
+// Handler moving the requested membership to status
+func transitionMembership(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		membership, err := db.getMembershipFromReq(w, r)
+		if err != nil {
+			return
+		}
+
+		err = db.transition(membership, status)
+		if _, ok := err.(*TransitionError); ok {
+			helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			log.Errorf("Error changing membership %d to %s in db: %s", membership.ID, status, err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+			return
+		}
+
+		json.NewEncoder(w).Encode(&membership)
+	}
+}
+
+// MemberRoutes set routes for memberships under /members
+func MemberRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/memberships", getMemberMemberships).Methods("GET")
+	router.HandleFunc("/{id}/memberships", addMemberMembership).Methods("POST")
+}
+
+// Routes set routes for /memberships
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Membership{}, &MembershipStyle{})
+
+	router.HandleFunc("/{id}", getMembership).Methods("GET")
+	router.HandleFunc("/{id}/pause", transitionMembership(StatusPaused)).Methods("POST")
+	router.HandleFunc("/{id}/resume", transitionMembership(StatusActive)).Methods("POST")
+	router.HandleFunc("/{id}/cancel", transitionMembership(StatusCancelled)).Methods("POST")
+}
@@ -0,0 +1,154 @@
+package memberships
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/members"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+	members.SetupExternally(members.Database{DB: gormDB})
+}
+
+// Mock the stored membership 2 with status
+func setStoredMembership(status string) {
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "memberships"`).WithRowsNum(1)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "memberships"`).WithReply([]map[string]interface{}{{
+		"id":         2,
+		"member_id":  5,
+		"start_date": time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"status":     status,
+	}})
+}
+
+func TestAddMembership(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"`).WithReply([]map[string]interface{}{{"id": 5, "name": "Tester"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "styles"  WHERE (name IN (ballet))`).WithReply([]map[string]interface{}{{"id": 3, "name": "ballet"}})
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "memberships"`).WithID(2)
+	var styleIDs []interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "membership_styles"`).WithCallback(func(query string, args []driver.NamedValue) {
+		styleIDs = append(styleIDs, args[1].Value)
+	})
+
+	w, r := makeRequest(map[string]interface{}{"start_date": "2019-08-01", "styles": []string{" Ballet", "ballet"}, "status": "paused"}, map[string]string{"id": "5"})
+	addMemberMembership(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var membership map[string]interface{}
+	err = json.Unmarshal(body, &membership)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if membership["id"] != float64(2) || membership["member_id"] != float64(5) || membership["status"] != StatusActive || membership["end_date"] != nil {
+		t.Error("Received membership didn't match expectations:", membership)
+	}
+	if len(styleIDs) != 1 || styleIDs[0] != int64(3) {
+		t.Error("Expected membership to be valid for ballet classes, got:", styleIDs)
+	}
+}
+
+func TestAddMembershipNoSuchStyle(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"`).WithReply([]map[string]interface{}{{"id": 5, "name": "Tester"}})
+
+	w, r := makeRequest(map[string]interface{}{"start_date": "2019-08-01", "styles": []string{"polka"}}, map[string]string{"id": "5"})
+	addMemberMembership(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestPauseMembership(t *testing.T) {
+	mocket.Catcher.Reset()
+	setStoredMembership(StatusActive)
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	transitionMembership(StatusPaused)(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var membership map[string]interface{}
+	err = json.Unmarshal(body, &membership)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if membership["status"] != StatusPaused || membership["paused_at"] == nil {
+		t.Error("Expected membership to be paused:", membership)
+	}
+}
+
+func TestResumeCancelledMembership(t *testing.T) {
+	mocket.Catcher.Reset()
+	setStoredMembership(StatusCancelled)
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	transitionMembership(StatusActive)(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestResumeExtendsEndDate(t *testing.T) {
+	endDate := time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC)
+	membership := Membership{Status: StatusActive, EndDate: &endDate}
+
+	pausedAt := time.Date(2019, 8, 10, 12, 0, 0, 0, time.UTC)
+	err := membership.Transition(StatusPaused, pausedAt)
+	if err != nil {
+		t.Error("Error pausing membership:", err)
+	}
+	err = membership.Transition(StatusActive, pausedAt.AddDate(0, 0, 7).Add(time.Hour))
+	if err != nil {
+		t.Error("Error resuming membership:", err)
+	}
+
+	if !membership.EndDate.Equal(time.Date(2019, 9, 7, 0, 0, 0, 0, time.UTC)) || membership.PausedAt != nil {
+		t.Error("Expected end date to move by the week paused:", membership.EndDate)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/memberships", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,161 @@
+package memberships
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Membership statuses
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+)
+
+// Statuses a membership can move to from each status
+var transitions = map[string][]string{
+	StatusActive: {StatusPaused, StatusCancelled},
+	StatusPaused: {StatusActive, StatusCancelled},
+}
+
+// TransitionError is returned for a status change the membership lifecycle doesn't allow
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("Membership can't be changed from %s to %s", e.From, e.To)
+}
+
+// Membership unlimited membership of a member, covering bookings of the classes it's valid for
+// while active
+type Membership struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	MemberID  uint64    `json:"member_id"`
+	StartDate time.Time `gorm:"type:date" json:"start_date"`
+	// Last date covered, or nil for a recurring membership which renews monthly until cancelled
+	EndDate     *time.Time `gorm:"type:date" json:"end_date"`
+	Status      string     `json:"status"`
+	PausedAt    *time.Time `json:"paused_at"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+	// Styles of the classes the membership is valid for, stored in membership_styles.
+	// Memberships valid for all classes have none.
+	Styles []string `gorm:"-" json:"styles"`
+}
+
+// MembershipStyle class style a membership is valid for
+type MembershipStyle struct {
+	MembershipID uint64 `gorm:"primary_key;auto_increment:false"`
+	StyleID      uint64 `gorm:"primary_key;auto_increment:false"`
+}
+
+// BeforeCreate activate new memberships
+func (m *Membership) BeforeCreate() error {
+	if m.Status == "" {
+		m.Status = StatusActive
+	}
+	return nil
+}
+
+// Transition move membership to status at time at. Resuming a membership with an end date
+// moves the end date later by the whole days it was paused.
+func (m *Membership) Transition(status string, at time.Time) error {
+	allowed := false
+	for _, next := range transitions[m.Status] {
+		if next == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return &TransitionError{From: m.Status, To: status}
+	}
+
+	switch status {
+	case StatusPaused:
+		m.PausedAt = &at
+	case StatusActive:
+		if m.EndDate != nil && m.PausedAt != nil {
+			endDate := m.EndDate.AddDate(0, 0, int(at.Sub(*m.PausedAt).Hours()/24))
+			m.EndDate = &endDate
+		}
+		m.PausedAt = nil
+	case StatusCancelled:
+		m.CancelledAt = &at
+	}
+	m.Status = status
+	return nil
+}
+
+// MarshalJSON to date correctly
+func (m *Membership) MarshalJSON() ([]byte, error) {
+	type Alias Membership
+	var endDate *string
+	if m.EndDate != nil {
+		date := m.EndDate.Format("2006-01-02")
+		endDate = &date
+	}
+	return json.Marshal(&struct {
+		StartDate string  `json:"start_date"`
+		EndDate   *string `json:"end_date"`
+		*Alias
+	}{
+		StartDate: m.StartDate.Format("2006-01-02"),
+		EndDate:   endDate,
+		Alias:     (*Alias)(m),
+	})
+}
+
+// UnmarshalJSON to date correctly and only accept the fields chosen when selling a membership
+func (m *Membership) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		StartDate string   `json:"start_date"`
+		EndDate   string   `json:"end_date"`
+		Styles    []string `json:"styles"`
+	}{}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if len(aux.StartDate) < 10 {
+		return errors.New("Invalid start_date in payload")
+	}
+
+	m.StartDate, err = time.Parse("2006-01-02", aux.StartDate[0:10])
+	if err != nil {
+		return err
+	}
+
+	m.EndDate = nil
+	if aux.EndDate != "" {
+		if len(aux.EndDate) < 10 {
+			return errors.New("Invalid end_date in payload")
+		}
+
+		endDate, err := time.Parse("2006-01-02", aux.EndDate[0:10])
+		if err != nil {
+			return err
+		}
+		if endDate.Before(m.StartDate) {
+			return errors.New("Invalid end_date in payload, must not be before start_date")
+		}
+		m.EndDate = &endDate
+	}
+
+	seen := map[string]bool{}
+	m.Styles = []string{}
+	for _, style := range aux.Styles {
+		style = strings.ToLower(strings.TrimSpace(style))
+		if style != "" && !seen[style] {
+			seen[style] = true
+			m.Styles = append(m.Styles, style)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+)
+
+// Create the bookings table. Installations from before the migration
+// layer already have it, created by AutoMigrate.
+func createBookings(tx *gorm.DB) error {
+	return tx.Exec("CREATE TABLE IF NOT EXISTS `bookings` (" +
+		"`id` bigint(20) unsigned NOT NULL AUTO_INCREMENT, " +
+		"`member_id` bigint(20) unsigned DEFAULT NULL, " +
+		"`booking_date` date DEFAULT NULL, " +
+		"`class_id` bigint(20) unsigned DEFAULT NULL, " +
+		"PRIMARY KEY (`id`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci").Error
+}
+
+// Allow a member only one booking per class session. Duplicates made before the
+// constraint are removed, keeping the first booking of each member on a session.
+func uniqueBookings(tx *gorm.DB) error {
+	result := tx.Exec(`DELETE b FROM bookings b
+		JOIN bookings k ON k.member_id = b.member_id AND k.class_id = b.class_id
+			AND k.booking_date = b.booking_date AND k.id < b.id`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Warnf("Removed %d duplicate bookings", result.RowsAffected)
+	}
+
+	return tx.Exec("CREATE UNIQUE INDEX `uix_bookings_member_class_date` ON `bookings` (`member_id`, `class_id`, `booking_date`)").Error
+}
@@ -0,0 +1,12 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Add the scale of class levels, from beginner to advanced. New styles and tags are
+// added as classes use them, but levels are only added here.
+func classLevels(tx *gorm.DB) error {
+	return tx.Exec("INSERT INTO `levels` (`name`, `position`) VALUES " +
+		"('beginner', 1), ('improver', 2), ('intermediate', 3), ('advanced', 4)").Error
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Create members from the free-text names bookings and waitlist entries used to have, so that
+// names differing only by case or surrounding whitespace become the same member.
+// Names already matching an existing member are linked to that member.
+func backfillMembers(tx *gorm.DB) error {
+	for _, table := range []string{"bookings", "waitlist_entries"} {
+		if !tx.Dialect().HasColumn(table, "name") {
+			continue
+		}
+
+		// Bookings from before members don't have the column yet, it's no longer added by AutoMigrate
+		if !tx.Dialect().HasColumn(table, "member_id") {
+			err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN `member_id` bigint(20) unsigned DEFAULT NULL").Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Exec(`INSERT INTO members (name, created_at)
+			SELECT MIN(TRIM(t.name)), UTC_TIMESTAMP() FROM ` + table + ` t
+			WHERE TRIM(COALESCE(t.name, '')) <> '' AND (t.member_id IS NULL OR t.member_id = 0)
+				AND NOT EXISTS (SELECT 1 FROM members m WHERE LOWER(m.name) = LOWER(TRIM(t.name)))
+			GROUP BY LOWER(TRIM(t.name))`).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`UPDATE ` + table + ` t
+			JOIN (SELECT LOWER(name) AS name, MIN(id) AS id FROM members GROUP BY LOWER(name)) m
+				ON m.name = LOWER(TRIM(t.name))
+			SET t.member_id = m.id
+			WHERE t.member_id IS NULL OR t.member_id = 0`).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+)
+
+// Migration change to the schema or data which AutoMigrate can't do, applied once
+type Migration struct {
+	ID string
+	Up func(tx *gorm.DB) error
+}
+
+// Record of an applied migration
+type appliedMigration struct {
+	ID        string    `gorm:"primary_key;type:varchar(255)"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName to keep the migration log apart from the studio data
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations in the order they are applied. Never reorder or remove applied migrations, only append.
+var migrations = []Migration{
+	{ID: "0001_backfill_members", Up: backfillMembers},
+	{ID: "0002_create_bookings", Up: createBookings},
+	{ID: "0003_unique_bookings", Up: uniqueBookings},
+	{ID: "0004_booking_statuses", Up: bookingStatuses},
+	{ID: "0005_late_cancellations", Up: lateCancellations},
+	{ID: "0006_class_levels", Up: classLevels},
+	{ID: "0007_booking_passes", Up: bookingPasses},
+	{ID: "0008_booking_memberships", Up: bookingMemberships},
+	{ID: "0009_booking_invoices", Up: bookingInvoices},
+	{ID: "0010_booking_redemptions", Up: bookingRedemptions},
+}
+
+// Run apply migrations which haven't been applied yet. Run after the package routes, as most
+// tables are still created by AutoMigrate there and migrations only change what already exists.
+func Run(db *gorm.DB) error {
+	err := db.AutoMigrate(&appliedMigration{}).Error
+	if err != nil {
+		return err
+	}
+
+	return run(db, migrations)
+}
+
+func run(db *gorm.DB, migrations []Migration) error {
+	for _, migration := range migrations {
+		var applied uint
+		err := db.Model(&appliedMigration{}).Where("id = ?", migration.ID).Count(&applied).Error
+		if err != nil {
+			return err
+		}
+
+		if applied > 0 {
+			continue
+		}
+
+		log.Infof("Applying migration %s", migration.ID)
+		err = apply(db, migration)
+		if err != nil {
+			log.Errorf("Migration %s failed: %s", migration.ID, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply migration and record it in the same transaction. Note that MySQL commits
+// schema changes implicitly, so only data changes are rolled back on failure.
+func apply(db *gorm.DB, migration Migration) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	err := migration.Up(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Create(&appliedMigration{ID: migration.ID, AppliedAt: time.Now().UTC()}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() *gorm.DB {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	return gormDB
+}
+
+func TestRunSkipsApplied(t *testing.T) {
+	db := setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "schema_migrations"  WHERE (id = 0001_done)`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "schema_migrations"  WHERE (id = 0002_new)`).WithReply([]map[string]interface{}{{"count(*)": 0}})
+
+	var recorded []string
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "schema_migrations"`).WithCallback(func(query string, args []driver.NamedValue) {
+		recorded = append(recorded, args[0].Value.(string))
+	})
+
+	var ran []string
+	err := run(db, []Migration{
+		{ID: "0001_done", Up: func(tx *gorm.DB) error {
+			ran = append(ran, "0001_done")
+			return nil
+		}},
+		{ID: "0002_new", Up: func(tx *gorm.DB) error {
+			ran = append(ran, "0002_new")
+			return nil
+		}},
+	})
+	if err != nil {
+		t.Error("Error running migrations:", err)
+	}
+
+	if len(ran) != 1 || ran[0] != "0002_new" {
+		t.Error("Expected only the new migration to run, ran:", ran)
+	}
+	if len(recorded) != 1 || recorded[0] != "0002_new" {
+		t.Error("Expected the new migration to be recorded, recorded:", recorded)
+	}
+}
+
+func TestRunStopsOnFailure(t *testing.T) {
+	db := setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "schema_migrations"`).WithReply([]map[string]interface{}{{"count(*)": 0}})
+
+	recorded := false
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "schema_migrations"`).WithCallback(func(query string, args []driver.NamedValue) {
+		recorded = true
+	})
+
+	ranAfterFailure := false
+	err := run(db, []Migration{
+		{ID: "0001_broken", Up: func(tx *gorm.DB) error {
+			return errors.New("broken")
+		}},
+		{ID: "0002_after", Up: func(tx *gorm.DB) error {
+			ranAfterFailure = true
+			return nil
+		}},
+	})
+	if err == nil {
+		t.Error("Expected failing migration to fail the run")
+	}
+
+	if recorded || ranAfterFailure {
+		t.Error("Expected nothing to be applied after a failed migration")
+	}
+}
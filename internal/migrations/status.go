@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Give bookings a status instead of deleting them, with the time of each status change.
+// Existing bookings are confirmed. The unique index only covers active bookings, so a
+// member can book a session again after cancelling: cancelled bookings have a NULL key,
+// and NULLs never clash in a unique index.
+func bookingStatuses(tx *gorm.DB) error {
+	statements := []string{
+		"ALTER TABLE `bookings` " +
+			"ADD COLUMN `status` varchar(20) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'confirmed', " +
+			"ADD COLUMN `created_at` timestamp NULL DEFAULT NULL, " +
+			"ADD COLUMN `confirmed_at` timestamp NULL DEFAULT NULL, " +
+			"ADD COLUMN `cancelled_at` timestamp NULL DEFAULT NULL, " +
+			"ADD COLUMN `attended_at` timestamp NULL DEFAULT NULL, " +
+			"ADD COLUMN `no_show_at` timestamp NULL DEFAULT NULL, " +
+			"ADD COLUMN `active_key` tinyint(1) GENERATED ALWAYS AS (IF(`status` = 'cancelled', NULL, 1)) STORED",
+		"ALTER TABLE `bookings` " +
+			"DROP INDEX `uix_bookings_member_class_date`, " +
+			"ADD UNIQUE INDEX `uix_bookings_member_class_date` (`member_id`, `class_id`, `booking_date`, `active_key`)",
+	}
+
+	for _, statement := range statements {
+		err := tx.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Mark bookings cancelled past the deadline of the cancellation policy
+func lateCancellations(tx *gorm.DB) error {
+	return tx.Exec("ALTER TABLE `bookings` ADD COLUMN `late_cancelled` tinyint(1) NOT NULL DEFAULT 0").Error
+}
+
+// Link bookings to the pass they used a credit of
+func bookingPasses(tx *gorm.DB) error {
+	return tx.Exec("ALTER TABLE `bookings` ADD COLUMN `pass_id` bigint(20) unsigned DEFAULT NULL").Error
+}
+
+// Link bookings to the membership covering them
+func bookingMemberships(tx *gorm.DB) error {
+	return tx.Exec("ALTER TABLE `bookings` ADD COLUMN `membership_id` bigint(20) unsigned DEFAULT NULL").Error
+}
+
+// Link bookings to the invoice of their drop-in price
+func bookingInvoices(tx *gorm.DB) error {
+	return tx.Exec("ALTER TABLE `bookings` ADD COLUMN `invoice_id` bigint(20) unsigned DEFAULT NULL").Error
+}
+
+// Link bookings to the redemption of the discount code taken off their invoice
+func bookingRedemptions(tx *gorm.DB) error {
+	if tx.Dialect().HasColumn("bookings", "redemption_id") {
+		return nil
+	}
+
+	return tx.Exec("ALTER TABLE `bookings` ADD COLUMN `redemption_id` bigint(20) unsigned DEFAULT NULL").Error
+}
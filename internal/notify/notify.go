@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Events members are notified about, each rendered from the template file of the same name
+const (
+	EventBookingCreated   = "booking_created"
+	EventBookingCancelled = "booking_cancelled"
+	EventClassChanged     = "class_changed"
+	EventSessionReminder  = "session_reminder"
+)
+
+var events = []string{EventBookingCreated, EventBookingCancelled, EventClassChanged, EventSessionReminder}
+
+// BookingNotice data of booking_created, booking_cancelled and session_reminder templates
+type BookingNotice struct {
+	MemberName string
+	ClassName  string
+	BookingID  uint64
+	// Date of the session as YYYY-MM-DD and its start time as HH:MM in the class timezone
+	Date      string
+	StartTime string
+	Status    string
+	// Whether the booking was cancelled too late to be refunded
+	LateCancelled bool
+}
+
+// ClassNotice data of class_changed templates
+type ClassNotice struct {
+	MemberName string
+	ClassName  string
+	// What changed, such as a session being cancelled or moved
+	Change string
+}
+
+// ErrUnknownEvent is returned when sending a notification for an event without a template
+var ErrUnknownEvent = errors.New("No template for event")
+
+// ErrNotSetUp is returned when sending a notification straight away before Setup
+var ErrNotSetUp = errors.New("Notifications are not set up")
+
+// Attempts made to send a message before giving up, and the delay before the first retry
+// which doubles on each attempt
+const maxAttempts = 5
+
+var retryDelay = 30 * time.Second
+
+// Messages waiting to be sent. Send drops messages rather than blocks when it's full.
+const queueSize = 256
+
+type delivery struct {
+	msg     Message
+	attempt int
+}
+
+type notifier struct {
+	mailer    Mailer
+	templates map[string]*template.Template
+	queue     chan delivery
+}
+
+var (
+	mu      sync.RWMutex
+	current *notifier
+)
+
+// LoadTemplates parse a template file <event>.tmpl for every event from dir. Each file
+// defines a "subject" and a "body" template.
+func LoadTemplates(dir string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for _, event := range events {
+		tmpl, err := template.ParseFiles(filepath.Join(dir, event+".tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{"subject", "body"} {
+			if tmpl.Lookup(name) == nil {
+				return nil, fmt.Errorf("Template %s.tmpl doesn't define %s", event, name)
+			}
+		}
+		templates[event] = tmpl
+	}
+	return templates, nil
+}
+
+// Setup send notifications with mailer, rendered from templates. Messages are sent in the
+// background one at a time. Until Setup is called notifications are not sent.
+func Setup(mailer Mailer, templates map[string]*template.Template) {
+	n := &notifier{
+		mailer:    mailer,
+		templates: templates,
+		queue:     make(chan delivery, queueSize),
+	}
+	go n.run()
+
+	mu.Lock()
+	current = n
+	mu.Unlock()
+}
+
+// Enabled tells whether notifications are sent, so that callers can skip gathering their data
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// Send render the template of event with data and queue the message to recipient without
+// waiting for it to be sent. Nothing is sent when notifications aren't set up.
+func Send(event string, to string, data interface{}) error {
+	mu.RLock()
+	n := current
+	mu.RUnlock()
+	if n == nil {
+		return nil
+	}
+
+	msg, err := n.render(event, to, data)
+	if err != nil {
+		return err
+	}
+
+	n.enqueue(delivery{msg: msg})
+	return nil
+}
+
+// SendNow render the template of event with data and send the message to recipient straight
+// away, returning the error of sending it. Failures aren't retried, for callers which keep
+// track of what they've sent and retry themselves.
+func SendNow(event string, to string, data interface{}) error {
+	mu.RLock()
+	n := current
+	mu.RUnlock()
+	if n == nil {
+		return ErrNotSetUp
+	}
+
+	msg, err := n.render(event, to, data)
+	if err != nil {
+		return err
+	}
+
+	return n.mailer.Send(msg)
+}
+
+// Render subject and body of the message for event
+func (n *notifier) render(event string, to string, data interface{}) (Message, error) {
+	tmpl, ok := n.templates[event]
+	if !ok {
+		return Message{}, ErrUnknownEvent
+	}
+
+	var subject, body bytes.Buffer
+	err := tmpl.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+	err = tmpl.ExecuteTemplate(&body, "body", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
+
+func (n *notifier) enqueue(d delivery) {
+	select {
+	case n.queue <- d:
+	default:
+		log.Errorf("Notification queue is full, dropping message %q to %s", d.msg.Subject, d.msg.To)
+	}
+}
+
+func (n *notifier) run() {
+	for d := range n.queue {
+		n.deliver(d)
+	}
+}
+
+// Send message, scheduling a retry with a doubled delay when sending fails
+func (n *notifier) deliver(d delivery) {
+	err := n.mailer.Send(d.msg)
+	if err == nil {
+		return
+	}
+
+	d.attempt++
+	if d.attempt >= maxAttempts {
+		log.Errorf("Giving up sending %q to %s after %d attempts: %s", d.msg.Subject, d.msg.To, d.attempt, err)
+		return
+	}
+
+	delay := retryDelay * time.Duration(1<<uint(d.attempt-1))
+	log.Warnf("Error sending %q to %s, retrying in %s: %s", d.msg.Subject, d.msg.To, delay, err)
+	time.AfterFunc(delay, func() {
+		n.enqueue(d)
+	})
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Mailer failing the first fail sends and recording the messages it sent
+type fakeMailer struct {
+	mu   sync.Mutex
+	fail int
+	sent []Message
+	done chan struct{}
+}
+
+func (m *fakeMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail > 0 {
+		m.fail--
+		return errors.New("Connection refused")
+	}
+	m.sent = append(m.sent, msg)
+	close(m.done)
+	return nil
+}
+
+// Set up notifications sent with mailer from the shipped templates
+func setup(t *testing.T, mailer Mailer) {
+	templates, err := LoadTemplates("../../templates/email")
+	if err != nil {
+		t.Fatal("Error loading templates:", err)
+	}
+	Setup(mailer, templates)
+}
+
+func TestRender(t *testing.T) {
+	setup(t, &fakeMailer{done: make(chan struct{})})
+
+	msg, err := current.render(EventBookingCreated, "anna@example.com", BookingNotice{
+		MemberName: "Anna",
+		ClassName:  "Salsa basics",
+		BookingID:  12,
+		Date:       "2019-07-15",
+		StartTime:  "18:30",
+		Status:     "pending",
+	})
+	if err != nil {
+		t.Fatal("Error rendering message:", err)
+	}
+
+	if msg.Subject != "Booking received: Salsa basics on 2019-07-15" {
+		t.Error("Subject didn't match expectations:", msg.Subject)
+	}
+	if !strings.HasPrefix(msg.Body, "Hi Anna,") || !strings.Contains(msg.Body, "held until the payment") {
+		t.Error("Body didn't match expectations:", msg.Body)
+	}
+
+	_, err = current.render("class_deleted", "anna@example.com", nil)
+	if err != ErrUnknownEvent {
+		t.Error("Expected unknown event to be rejected, got:", err)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	retryDelay = time.Millisecond
+	mailer := &fakeMailer{fail: 2, done: make(chan struct{})}
+	setup(t, mailer)
+
+	err := Send(EventClassChanged, "anna@example.com", ClassNotice{MemberName: "Anna", ClassName: "Salsa basics", Change: "The session on 2019-07-15 has been cancelled."})
+	if err != nil {
+		t.Fatal("Error sending message:", err)
+	}
+
+	select {
+	case <-mailer.done:
+	case <-time.After(time.Second):
+		t.Fatal("Message wasn't sent after retrying")
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].Subject != "Changes to Salsa basics" {
+		t.Error("Sent messages didn't match expectations:", mailer.sent)
+	}
+}
+
+func TestSendNow(t *testing.T) {
+	mailer := &fakeMailer{fail: 1, done: make(chan struct{})}
+	setup(t, mailer)
+	notice := BookingNotice{MemberName: "Anna", ClassName: "Salsa basics", BookingID: 12, Date: "2019-07-15", StartTime: "18:30", Status: "confirmed"}
+
+	err := SendNow(EventSessionReminder, "anna@example.com", notice)
+	if err == nil {
+		t.Error("Expected the failed send to be reported")
+	}
+
+	err = SendNow(EventSessionReminder, "anna@example.com", notice)
+	if err != nil {
+		t.Error("Error sending message:", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Error("Expected the message to be sent once without retrying, got:", mailer.sent)
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Error starting test SMTP server:", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(listener, received)
+
+	mailer := &SMTPMailer{Address: listener.Addr().String(), From: "studio@example.com"}
+	err = mailer.Send(Message{To: "anna@example.com", Subject: "Booking confirmed", Body: "Hi Anna,\n\nSee you!\n"})
+	if err != nil {
+		t.Fatal("Error sending message:", err)
+	}
+
+	data := <-received
+	if !strings.Contains(data, "To: anna@example.com\r\n") || !strings.Contains(data, "Subject: Booking confirmed\r\n") || !strings.Contains(data, "\r\n\r\nHi Anna,\r\n\r\nSee you!\r\n") {
+		t.Error("Received message didn't match expectations:", data)
+	}
+}
+
+func TestSMTPMailerTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { sendTimeout = timeout }(sendTimeout)
+	sendTimeout = 50 * time.Millisecond
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Error starting test SMTP server:", err)
+	}
+	defer listener.Close()
+
+	// Accept the connection but never greet
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	mailer := &SMTPMailer{Address: listener.Addr().String(), From: "studio@example.com"}
+	started := time.Now()
+	err = mailer.Send(Message{To: "anna@example.com", Subject: "Booking confirmed", Body: "Hi Anna"})
+	if err == nil || time.Since(started) > 500*time.Millisecond {
+		t.Error("Expected sending to an unresponsive server to time out, got:", err, time.Since(started))
+	}
+}
+
+// Accept a single SMTP session and pass on the message data it delivers
+func serveSMTP(listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost test server")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err = reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			received <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package passes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// ErrNoSuchClass is returned when making a pass type valid for a class which doesn't exist
+var ErrNoSuchClass = errors.New("No such class")
+
+// ErrHasPasses is returned when removing a pass type which has been sold
+var ErrHasPasses = errors.New("Passes of the type have been sold and it can't be removed")
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// UseCredit use up a credit of a pass of member valid for a session of the class on date, inside
+// transaction tx. The pass expiring first is used. Returns nil when member has no such pass.
+func UseCredit(tx *gorm.DB, memberID uint64, classID uint64, date time.Time) (*Pass, error) {
+	var pass Pass
+	err := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("member_id = ? AND remaining > 0 AND expires_at >= ?", memberID, date).
+		Where("pass_type_id NOT IN (SELECT pass_type_id FROM pass_type_classes) OR "+
+			"pass_type_id IN (SELECT pass_type_id FROM pass_type_classes WHERE class_id = ?)", classID).
+		Order("expires_at ASC, id ASC").
+		First(&pass).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pass.Remaining--
+	err = tx.Model(&pass).Update("remaining", pass.Remaining).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &pass, nil
+}
+
+// RefundCredit give back a credit used from pass passID, inside transaction tx
+func RefundCredit(tx *gorm.DB, passID uint64) error {
+	var pass Pass
+	err := tx.Set("gorm:query_option", "FOR UPDATE").First(&pass, passID).Error
+	if err != nil {
+		return err
+	}
+
+	if pass.Remaining >= pass.Credits {
+		return nil
+	}
+
+	return tx.Model(&pass).Update("remaining", pass.Remaining+1).Error
+}
+
+// Load ids of the classes pass types are valid for
+func loadClassIDs(tx *gorm.DB, passTypes []PassType) error {
+	if len(passTypes) == 0 {
+		return nil
+	}
+
+	passTypeIDs := make([]uint64, len(passTypes))
+	for i := range passTypes {
+		passTypeIDs[i] = passTypes[i].ID
+		passTypes[i].ClassIDs = []uint64{}
+	}
+
+	var validFor []PassTypeClass
+	err := tx.Where("pass_type_id IN (?)", passTypeIDs).Order("class_id ASC").Find(&validFor).Error
+	if err != nil {
+		return err
+	}
+
+	for _, valid := range validFor {
+		for i := range passTypes {
+			if passTypes[i].ID == valid.PassTypeID {
+				passTypes[i].ClassIDs = append(passTypes[i].ClassIDs, valid.ClassID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Insert or update pass type with the classes it's valid for in one transaction
+func (db *Database) savePassType(passType *PassType) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if len(passType.ClassIDs) > 0 {
+		var found uint
+		err := tx.Model(&classes.Class{}).Where("id IN (?)", passType.ClassIDs).Count(&found).Error
+		if err != nil {
+			return err
+		}
+		if found != uint(len(passType.ClassIDs)) {
+			return ErrNoSuchClass
+		}
+	}
+
+	err := tx.Save(passType).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Where("pass_type_id = ?", passType.ID).Delete(&PassTypeClass{}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, classID := range passType.ClassIDs {
+		err = tx.Create(&PassTypeClass{PassTypeID: passType.ID, ClassID: classID}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Count passes sold of pass type
+func (db *Database) countPasses(passTypeID uint64) (uint, error) {
+	var count uint
+	err := db.Model(&Pass{}).Where("pass_type_id = ?", passTypeID).Count(&count).Error
+	return count, err
+}
+
+// Get pass type from database by id in request and handle error situations
+func (db *Database) getPassTypeFromReq(w http.ResponseWriter, r *http.Request) (*PassType, error) {
+	var passType PassType
+	vars := mux.Vars(r)
+	passTypeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested pass type id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid pass type ID")
+		return nil, err
+	}
+
+	err = db.First(&passType, passTypeID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested pass type by id %d does not exist", passTypeID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Pass type does not exist")
+		} else {
+			log.Error("Error fetching pass type from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+
+	passTypes := []PassType{passType}
+	err = loadClassIDs(db.DB, passTypes)
+	if err != nil {
+		log.Error("Error fetching classes of pass type from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return nil, err
+	}
+	return &passTypes[0], nil
+}
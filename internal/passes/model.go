@@ -0,0 +1,100 @@
+package passes
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// PassType kind of pass sold to members, such as a 10-class pass valid for three months
+type PassType struct {
+	ID        uint64 `gorm:"primary_key" json:"id"`
+	Name      string `json:"name"`
+	Credits   uint   `json:"credits"`
+	ValidDays uint   `json:"valid_days"`
+	// Classes the pass is valid for, stored in pass_type_classes. Passes valid for all classes have none.
+	ClassIDs []uint64 `gorm:"-" json:"class_ids"`
+}
+
+// PassTypeClass class a pass type is valid for
+type PassTypeClass struct {
+	PassTypeID uint64 `gorm:"primary_key;auto_increment:false"`
+	ClassID    uint64 `gorm:"primary_key;auto_increment:false"`
+}
+
+// Pass credits bought by a member. Each booking paid with the pass uses up a credit.
+type Pass struct {
+	ID          uint64    `gorm:"primary_key" json:"id"`
+	MemberID    uint64    `json:"member_id"`
+	PassTypeID  uint64    `json:"pass_type_id"`
+	Credits     uint      `json:"credits"`
+	Remaining   uint      `json:"remaining"`
+	PurchasedAt time.Time `json:"purchased_at"`
+	// Last date sessions can be booked with the pass
+	ExpiresAt time.Time `gorm:"type:date" json:"expires_at"`
+}
+
+// UnmarshalJSON to validate pass type and strip ID from requests
+func (p *PassType) UnmarshalJSON(data []byte) error {
+	type Alias PassType
+	aux := &struct {
+		ID uint64 `gorm:"-" sql:"-" json:"id"`
+		*Alias
+	}{
+		Alias: (*Alias)(p),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("Missing name in payload")
+	}
+	if p.Credits < 1 {
+		return errors.New("Invalid credits in payload, must to be over 0")
+	}
+	if p.ValidDays < 1 {
+		return errors.New("Invalid valid_days in payload, must to be over 0")
+	}
+
+	seen := map[uint64]bool{}
+	classIDs := []uint64{}
+	for _, classID := range p.ClassIDs {
+		if !seen[classID] {
+			seen[classID] = true
+			classIDs = append(classIDs, classID)
+		}
+	}
+	p.ClassIDs = classIDs
+
+	return nil
+}
+
+// MarshalJSON to output the expiry date without time
+func (p *Pass) MarshalJSON() ([]byte, error) {
+	type Alias Pass
+	return json.Marshal(&struct {
+		ExpiresAt string `json:"expires_at"`
+		*Alias
+	}{
+		ExpiresAt: p.ExpiresAt.Format("2006-01-02"),
+		Alias:     (*Alias)(p),
+	})
+}
+
+// Sell pass of passType to member at purchasedAt, valid until the end of its last valid day
+func newPass(memberID uint64, passType PassType, purchasedAt time.Time) Pass {
+	purchaseDate := time.Date(purchasedAt.Year(), purchasedAt.Month(), purchasedAt.Day(), 0, 0, 0, 0, time.UTC)
+	return Pass{
+		MemberID:    memberID,
+		PassTypeID:  passType.ID,
+		Credits:     passType.Credits,
+		Remaining:   passType.Credits,
+		PurchasedAt: purchasedAt,
+		ExpiresAt:   purchaseDate.AddDate(0, 0, int(passType.ValidDays)-1),
+	}
+}
@@ -0,0 +1,194 @@
+package passes
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+func getPassTypes(w http.ResponseWriter, r *http.Request) {
+	var passTypes []PassType
+	err := db.Find(&passTypes).Error
+	if err == nil {
+		err = loadClassIDs(db.DB, passTypes)
+	}
+
+	if err != nil {
+		log.Error("Error fetching pass types from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&passTypes)
+}
+
+func addPassType(w http.ResponseWriter, r *http.Request) {
+	var passType PassType
+	err := json.NewDecoder(r.Body).Decode(&passType)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new pass type: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for pass type")
+		return
+	}
+
+	err = db.savePassType(&passType)
+	if err == ErrNoSuchClass {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error inserting pass type to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&passType)
+}
+
+func getPassType(w http.ResponseWriter, r *http.Request) {
+	passType, err := db.getPassTypeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&passType)
+}
+
+func updatePassType(w http.ResponseWriter, r *http.Request) {
+	passType, err := db.getPassTypeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&passType)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating pass type: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for pass type")
+		return
+	}
+
+	err = db.savePassType(passType)
+	if err == ErrNoSuchClass {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error saving pass type to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&passType)
+}
+
+func deletePassType(w http.ResponseWriter, r *http.Request) {
+	passType, err := db.getPassTypeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	sold, err := db.countPasses(passType.ID)
+	if err != nil {
+		log.Error("Error counting passes of pass type from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if sold > 0 {
+		helpers.ResponseJSON(w, http.StatusConflict, ErrHasPasses.Error())
+		return
+	}
+
+	err = db.Delete(&passType).Error
+	if err == nil {
+		err = db.Where("pass_type_id = ?", passType.ID).Delete(&PassTypeClass{}).Error
+	}
+	if err != nil {
+		log.Error("Error deleting pass type from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Pass type removed")
+}
+
+func getMemberPasses(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var passes []Pass
+	err = db.Where("member_id = ?", member.ID).Order("expires_at ASC").Find(&passes).Error
+	if err != nil {
+		log.Error("Error fetching passes of member from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&passes)
+}
+
+func sellPass(w http.ResponseWriter, r *http.Request) {
+	member, err := members.GetMemberFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var purchase struct {
+		PassTypeID uint64 `json:"pass_type_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&purchase)
+	if err != nil {
+		log.Warn("Error parsing JSON when selling pass: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for pass")
+		return
+	}
+
+	var passType PassType
+	err = db.First(&passType, purchase.PassTypeID).Error
+	if gorm.IsRecordNotFoundError(err) {
+		helpers.ResponseJSON(w, http.StatusBadRequest, "No such pass type")
+		return
+	}
+	if err != nil {
+		log.Error("Error fetching pass type from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	pass := newPass(member.ID, passType, time.Now().UTC())
+	err = db.Create(&pass).Error
+	if err != nil {
+		log.Error("Error inserting pass to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&pass)
+}
+
+// MemberRoutes set routes for passes under /members
+func MemberRoutes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+
+	router.HandleFunc("/{id}/passes", getMemberPasses).Methods("GET")
+	router.HandleFunc("/{id}/passes", sellPass).Methods("POST")
+}
+
+// Routes set routes for /pass-types
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&PassType{}, &PassTypeClass{}, &Pass{})
+
+	router.HandleFunc("", getPassTypes).Methods("GET")
+	router.HandleFunc("", addPassType).Methods("POST")
+	router.HandleFunc("/{id}", getPassType).Methods("GET")
+	router.HandleFunc("/{id}", updatePassType).Methods("PUT")
+	router.HandleFunc("/{id}", deletePassType).Methods("DELETE")
+}
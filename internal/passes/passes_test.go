@@ -0,0 +1,172 @@
+package passes
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/members"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+	members.SetupExternally(members.Database{DB: gormDB})
+}
+
+func TestAddPassType(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "classes"  WHERE (id IN (1,2))`).WithReply([]map[string]interface{}{{"count(*)": 2}})
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "pass_types"`).WithID(4)
+	var validFor []interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "pass_type_classes"`).WithCallback(func(query string, args []driver.NamedValue) {
+		validFor = append(validFor, args[1].Value)
+	})
+
+	w, r := makeRequest(map[string]interface{}{"name": "10-class pass", "credits": 10, "valid_days": 90, "class_ids": []int{1, 2, 1}}, nil)
+	addPassType(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var passType PassType
+	err = json.Unmarshal(body, &passType)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if passType.Name != "10-class pass" || passType.Credits != 10 || len(passType.ClassIDs) != 2 {
+		t.Error("Received pass type didn't match expectations:", passType)
+	}
+	if len(validFor) != 2 {
+		t.Error("Expected pass type to be made valid for both classes, got:", validFor)
+	}
+}
+
+func TestAddPassTypeNoSuchClass(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "classes"`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+
+	w, r := makeRequest(map[string]interface{}{"name": "Ballet pass", "credits": 5, "valid_days": 30, "class_ids": []int{1, 9}}, nil)
+	addPassType(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestSellPass(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"`).WithReply([]map[string]interface{}{{"id": 5, "name": "Tester"}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "pass_types"`).WithReply([]map[string]interface{}{{
+		"id":         4,
+		"name":       "10-class pass",
+		"credits":    10,
+		"valid_days": 90,
+	}})
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "passes"`).WithID(7)
+
+	w, r := makeRequest(map[string]interface{}{"pass_type_id": 4}, map[string]string{"id": "5"})
+	sellPass(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var pass map[string]interface{}
+	err = json.Unmarshal(body, &pass)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	expires := time.Now().UTC().AddDate(0, 0, 89).Format("2006-01-02")
+	if pass["id"] != float64(7) || pass["member_id"] != float64(5) || pass["remaining"] != float64(10) || pass["expires_at"] != expires {
+		t.Error("Received pass didn't match expectations:", pass)
+	}
+}
+
+func TestUseCredit(t *testing.T) {
+	mocket.Catcher.Reset()
+	var query string
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "passes"`).WithCallback(func(q string, args []driver.NamedValue) {
+		query = q
+	}).WithReply([]map[string]interface{}{{
+		"id":        7,
+		"member_id": 5,
+		"credits":   10,
+		"remaining": 3,
+	}})
+	var remaining interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "passes"`).WithRowsNum(1).WithCallback(func(q string, args []driver.NamedValue) {
+		remaining = args[0].Value
+	})
+
+	pass, err := UseCredit(db.DB, 5, 1, time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error using pass credit:", err)
+	}
+
+	if pass == nil || pass.ID != 7 || remaining != int64(2) {
+		t.Error("Expected a credit of pass 7 to be used, got:", pass, remaining)
+	}
+	if !strings.Contains(query, "pass_type_classes") || !strings.Contains(query, "FOR UPDATE") {
+		t.Error("Expected the pass to be locked and checked against the classes it's valid for:", query)
+	}
+}
+
+func TestUseCreditWithoutPass(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	pass, err := UseCredit(db.DB, 5, 1, time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil || pass != nil {
+		t.Error("Expected no pass to be used, got:", pass, err)
+	}
+}
+
+func TestRefundCreditOfFullPass(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "passes"`).WithReply([]map[string]interface{}{{"id": 7, "credits": 10, "remaining": 10}})
+	updated := false
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "passes"`).WithCallback(func(q string, args []driver.NamedValue) {
+		updated = true
+	})
+
+	err := RefundCredit(db.DB, 7)
+	if err != nil || updated {
+		t.Error("Expected a pass with all its credits not to get more, got:", err)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/pass-types", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
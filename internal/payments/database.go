@@ -0,0 +1,354 @@
+package payments
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/invoices"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+var provider PaymentProvider = noProvider{}
+var paymentWindow = 30 * time.Minute
+
+// SetProvider set payment provider new payments are made through. Until one is set,
+// payments fail with ErrNoProvider. Set before Routes.
+func SetProvider(p PaymentProvider) {
+	provider = p
+}
+
+// Tells if payments are made through the fake provider, confirmed without being paid
+func usingFakeProvider() bool {
+	_, fake := provider.(*FakeProvider)
+	return fake
+}
+
+// SetPaymentWindow set how long a payment can be completed before it's given up
+func SetPaymentWindow(window time.Duration) {
+	paymentWindow = window
+}
+
+// SettledHandler is called inside the transaction which moved a payment out of pending,
+// either paid or given up
+type SettledHandler func(tx *gorm.DB, payment Payment) error
+
+var settledHandlers []SettledHandler
+
+// OnSettled register handler to be called whenever a pending payment succeeds or is given up
+func OnSettled(handler SettledHandler) {
+	settledHandlers = append(settledHandlers, handler)
+}
+
+// SettlingHandler is called inside the transaction about to settle a payment reported by the
+// provider, before the payment is locked
+type SettlingHandler func(tx *gorm.DB, payment Payment) error
+
+var settlingHandlers []SettlingHandler
+
+// OnSettling register handler to be called before a payment is locked to be settled, so that
+// rows changed by the settled handlers can be locked first. Changes made from the other side,
+// such as cancelling a booking, lock those rows before the payment, and the same order here
+// keeps the two from deadlocking.
+func OnSettling(handler SettlingHandler) {
+	settlingHandlers = append(settlingHandlers, handler)
+}
+
+// Lock payment by id in transaction tx to be settled, after the settling handlers
+func lockForSettling(tx *gorm.DB, payment Payment) (*Payment, error) {
+	for _, handler := range settlingHandlers {
+		err := handler(tx, payment)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lockPayment(tx, payment.ID)
+}
+
+// Start payment of invoice inside transaction tx. The payment is recorded pending, and its
+// intent is created at the provider with Open once the transaction has been committed.
+func Start(tx *gorm.DB, invoice *invoices.Invoice) (*Payment, error) {
+	if _, none := provider.(noProvider); none {
+		return nil, ErrNoProvider
+	}
+
+	now := time.Now().UTC()
+	payment := Payment{
+		InvoiceID: invoice.ID,
+		Provider:  provider.Name(),
+		Amount:    invoice.Total,
+		Currency:  invoice.Currency,
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(paymentWindow),
+		reference: fmt.Sprintf("Invoice %d", invoice.Number),
+	}
+	err := tx.Create(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// Open payment started in a committed transaction by creating its intent at the provider, outside
+// of any transaction so that none is held open while talking to the provider. When that fails, the
+// payment is given up, releasing what it was for, and the error is returned.
+func Open(payment *Payment) error {
+	intent, err := provider.CreateIntent(payment.Amount, payment.Currency, payment.reference)
+	if err == nil {
+		err = db.Model(payment).Update("intent_id", &intent.ID).Error
+	}
+	if err != nil {
+		giveUpErr := db.applyStatus(payment, StatusFailed)
+		if giveUpErr != nil {
+			log.Errorf("Error giving up payment %d: %s", payment.ID, giveUpErr)
+		}
+		return err
+	}
+
+	payment.IntentID = &intent.ID
+	payment.ClientSecret = intent.ClientSecret
+	return nil
+}
+
+// Move locked payment to status as reported by the provider, inside transaction tx. A payment
+// succeeding after it was given up is refunded, as there's nothing left to pay for. Refunds are
+// only recorded here, and made at the provider by RefundDue once committed.
+func settle(tx *gorm.DB, payment *Payment, status string) error {
+	if payment.Status == status {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	wasPending := payment.Status == StatusPending
+	switch {
+	case wasPending && status == StatusSucceeded:
+		payment.PaidAt = &now
+	case wasPending && (status == StatusFailed || status == StatusExpired || status == StatusCancelled):
+		// Given up, handlers release what the payment was for
+	case status == StatusSucceeded && (payment.Status == StatusFailed || payment.Status == StatusExpired || payment.Status == StatusCancelled):
+		log.Warnf("Payment %d succeeded after it was %s, refunding it", payment.ID, payment.Status)
+		payment.PaidAt = &now
+		status = StatusRefunding
+	case payment.Status == StatusSucceeded && status == StatusRefunding:
+		// Refunded at the provider by RefundDue
+	case (payment.Status == StatusSucceeded || payment.Status == StatusRefunding) && status == StatusRefunded:
+		payment.RefundedAt = &now
+	default:
+		return &TransitionError{From: payment.Status, To: status}
+	}
+
+	payment.Status = status
+	err := tx.Save(payment).Error
+	if err != nil || !wasPending {
+		return err
+	}
+
+	for _, handler := range settledHandlers {
+		err = handler(tx, *payment)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lock payment by id in transaction tx
+func lockPayment(tx *gorm.DB, paymentID uint64) (*Payment, error) {
+	var payment Payment
+	err := tx.Set("gorm:query_option", "FOR UPDATE").First(&payment, paymentID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Cancel payment of invoice inside transaction tx, when what it pays for is cancelled.
+// A pending payment is given up, and a succeeded one is refunded when refund is set.
+func Cancel(tx *gorm.DB, invoiceID uint64, refund bool) error {
+	var payment Payment
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where("invoice_id = ?", invoiceID).First(&payment).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case payment.Status == StatusPending:
+		return settle(tx, &payment, StatusCancelled)
+	case payment.Status == StatusSucceeded && refund:
+		return settle(tx, &payment, StatusRefunding)
+	}
+
+	return nil
+}
+
+// Apply status reported by the provider to payment in a transaction which locks it first
+func (db *Database) applyStatus(payment *Payment, status string) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	locked, err := lockForSettling(tx, *payment)
+	if err != nil {
+		return err
+	}
+
+	err = settle(tx, locked, status)
+	if err != nil {
+		return err
+	}
+	*payment = *locked
+
+	return tx.Commit().Error
+}
+
+// ExpireOverdue give up pending payments which weren't completed in time, each in its own
+// transaction so that one failing doesn't hold back the rest. Returns how many expired.
+func ExpireOverdue(now time.Time) (int, error) {
+	var overdue []Payment
+	err := db.Where("status = ? AND expires_at < ?", StatusPending, now).Order("expires_at ASC").Find(&overdue).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range overdue {
+		err = db.expire(&overdue[i])
+		if err != nil {
+			log.Errorf("Error expiring payment %d: %s", overdue[i].ID, err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// Expire payment unless it has been settled since it was found overdue
+func (db *Database) expire(payment *Payment) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	locked, err := lockForSettling(tx, *payment)
+	if err != nil {
+		return err
+	}
+	if locked.Status != StatusPending {
+		return nil
+	}
+
+	err = settle(tx, locked, StatusExpired)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// RefundDue make the refunds recorded since the last run at the provider, each outside of any
+// transaction so that none is held open while talking to the provider. Refunds which fail are
+// retried on the next run. Returns how many were made.
+func RefundDue() (int, error) {
+	var due []Payment
+	err := db.Where("status = ?", StatusRefunding).Order("id ASC").Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	refunded := 0
+	for i := range due {
+		err = db.refund(&due[i])
+		if err != nil {
+			log.Errorf("Error refunding payment %d: %s", due[i].ID, err)
+			continue
+		}
+		refunded++
+	}
+
+	return refunded, nil
+}
+
+// Refund payment at the provider and record it refunded, unless it has been settled since it was found
+func (db *Database) refund(payment *Payment) error {
+	if payment.IntentID == nil {
+		return fmt.Errorf("Payment %d has no intent to refund", payment.ID)
+	}
+
+	err := provider.Refund(*payment.IntentID, payment.Amount)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	locked, err := lockPayment(tx, payment.ID)
+	if err != nil {
+		return err
+	}
+	if locked.Status != StatusRefunding {
+		return nil
+	}
+
+	err = settle(tx, locked, StatusRefunded)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Get payment from database by id in request and handle error situations
+func (db *Database) getPaymentFromReq(w http.ResponseWriter, r *http.Request) (*Payment, error) {
+	var payment Payment
+	vars := mux.Vars(r)
+	paymentID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested payment id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid payment ID")
+		return nil, err
+	}
+
+	err = db.First(&payment, paymentID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested payment by id %d does not exist", paymentID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Payment does not exist")
+		} else {
+			log.Error("Error fetching payment from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
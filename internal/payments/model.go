@@ -0,0 +1,62 @@
+package payments
+
+import (
+	"fmt"
+	"time"
+)
+
+// Payment statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+	StatusRefunding = "refunding"
+	StatusRefunded  = "refunded"
+)
+
+// Payment representation of payments.payments, the online payment of an invoice through a
+// payment provider. Amounts are in cents.
+type Payment struct {
+	ID        uint64 `gorm:"primary_key" json:"id"`
+	InvoiceID uint64 `gorm:"index" json:"invoice_id"`
+	Provider  string `json:"provider"`
+	// Id of the payment intent at the provider, nil until the payment is opened
+	IntentID *string `gorm:"unique_index" json:"intent_id"`
+	// Handed to the client to complete the payment, only known when the payment is opened
+	ClientSecret string    `gorm:"-" json:"client_secret,omitempty"`
+	Amount       uint      `json:"amount"`
+	Currency     string    `gorm:"type:char(3)" json:"currency"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	// Payment is given up if it hasn't succeeded by then
+	ExpiresAt  time.Time  `json:"expires_at"`
+	PaidAt     *time.Time `json:"paid_at"`
+	RefundedAt *time.Time `json:"refunded_at"`
+	// Described to the payer when the intent is created
+	reference string
+}
+
+// TransitionError is returned for a status change which doesn't apply to the payment
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("Payment can't be changed from %s to %s", e.From, e.To)
+}
+
+// Intent payment intent at the provider. Status is one of the payment statuses.
+type Intent struct {
+	ID           string
+	Status       string
+	ClientSecret string
+}
+
+// Event webhook notification of a provider about a change in a payment intent
+type Event struct {
+	IntentID string `json:"intent_id"`
+	Status   string `json:"status"`
+}
@@ -0,0 +1,106 @@
+package payments
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := db.getPaymentFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&payment)
+}
+
+func confirmPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := db.getPaymentFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	if payment.Status != StatusPending {
+		helpers.ResponseJSON(w, http.StatusConflict, (&TransitionError{From: payment.Status, To: StatusSucceeded}).Error())
+		return
+	}
+
+	if payment.IntentID == nil {
+		helpers.ResponseJSON(w, http.StatusConflict, "Payment hasn't been opened at the provider")
+		return
+	}
+
+	intent, err := provider.Confirm(*payment.IntentID)
+	if err != nil {
+		log.Error("Error confirming payment with provider: ", err)
+		helpers.ResponseJSON(w, http.StatusBadGateway, "Payment provider failed to confirm the payment")
+		return
+	}
+
+	err = db.applyStatus(payment, intent.Status)
+	if err != nil {
+		if _, ok := err.(*TransitionError); ok {
+			helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+			return
+		}
+		log.Error("Error saving payment to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&payment)
+}
+
+func handleWebhook(w http.ResponseWriter, r *http.Request) {
+	event, err := provider.ParseWebhook(r)
+	if err != nil {
+		log.Warn("Invalid payment webhook: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid webhook")
+		return
+	}
+
+	var payment Payment
+	err = db.Where("intent_id = ?", event.IntentID).First(&payment).Error
+	if gorm.IsRecordNotFoundError(err) {
+		// Acknowledged so that the provider doesn't keep retrying intents which aren't ours
+		log.Warnf("Payment webhook for unknown intent %s", event.IntentID)
+		helpers.ResponseJSON(w, http.StatusOK, "Unknown payment intent")
+		return
+	}
+	if err != nil {
+		log.Error("Error fetching payment from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	err = db.applyStatus(&payment, event.Status)
+	if err != nil {
+		if _, ok := err.(*TransitionError); ok {
+			log.Warnf("Ignored payment webhook for intent %s: %s", event.IntentID, err)
+			helpers.ResponseJSON(w, http.StatusOK, err.Error())
+			return
+		}
+		log.Error("Error saving payment to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&payment)
+}
+
+// Routes set routes for /payments. Payments can be confirmed directly only with the fake provider.
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Payment{})
+
+	router.HandleFunc("/webhook", handleWebhook).Methods("POST")
+	router.HandleFunc("/{id}", getPayment).Methods("GET")
+	if usingFakeProvider() {
+		router.HandleFunc("/{id}/confirm", confirmPayment).Methods("POST")
+	}
+}
@@ -0,0 +1,282 @@
+package payments
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+	SetProvider(NewFakeProvider())
+}
+
+// Mock pending payment 3 of invoice 7 paid through intent
+func setPaymentMatch(intentID string, status string) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "payments"`).WithReply([]map[string]interface{}{{
+		"id":         3,
+		"invoice_id": 7,
+		"provider":   "fake",
+		"intent_id":  intentID,
+		"amount":     1500,
+		"currency":   "EUR",
+		"status":     status,
+		"expires_at": time.Now().UTC().Add(-time.Minute),
+	}})
+}
+
+// Record statuses payments are saved with
+func setPaymentUpdate() *[]interface{} {
+	var saved []interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "payments"`).WithCallback(func(query string, args []driver.NamedValue) {
+		// invoice_id, provider, intent_id, amount, currency, status, ...
+		saved = append(saved, args[5].Value)
+	}).WithRowsNum(1)
+	return &saved
+}
+
+// Record payments handed to settled handlers, clearing those registered before
+func recordSettled() *[]Payment {
+	var settled []Payment
+	settledHandlers = nil
+	OnSettled(func(tx *gorm.DB, payment Payment) error {
+		settled = append(settled, payment)
+		return nil
+	})
+	return &settled
+}
+
+func TestFakeProvider(t *testing.T) {
+	provider := NewFakeProvider()
+
+	intent, err := provider.CreateIntent(1500, "EUR", "Invoice 1")
+	if err != nil || intent.Status != StatusPending || intent.ClientSecret == "" {
+		t.Error("Unexpected intent:", intent, err)
+	}
+
+	err = provider.Refund(intent.ID, 1500)
+	if err == nil {
+		t.Error("Expected refunding a pending intent to fail")
+	}
+
+	intent, err = provider.Confirm(intent.ID)
+	if err != nil || intent.Status != StatusSucceeded {
+		t.Error("Expected intent to succeed when confirmed:", intent, err)
+	}
+
+	err = provider.Refund(intent.ID, 1500)
+	if err != nil {
+		t.Error("Error refunding succeeded intent:", err)
+	}
+
+	_, err = provider.Confirm("fake_pi_999")
+	if err != ErrNoSuchIntent {
+		t.Error("Expected confirming an unknown intent to fail, got:", err)
+	}
+}
+
+func TestConfirmPayment(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	intent, _ := provider.CreateIntent(1500, "EUR", "Invoice 1")
+	setPaymentMatch(intent.ID, StatusPending)
+	saved := setPaymentUpdate()
+	settled := recordSettled()
+
+	w, r := makeRequest("", map[string]string{"id": "3"})
+	confirmPayment(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var payment Payment
+	err = json.Unmarshal(body, &payment)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if payment.Status != StatusSucceeded || payment.PaidAt == nil {
+		t.Error("Expected payment to succeed:", payment)
+	}
+	if len(*saved) != 1 || (*saved)[0] != StatusSucceeded {
+		t.Error("Expected succeeded payment to be saved, got:", *saved)
+	}
+	if len(*settled) != 1 || (*settled)[0].InvoiceID != 7 {
+		t.Error("Expected settled handlers to be called with the payment, got:", *settled)
+	}
+}
+
+func TestWebhookFailed(t *testing.T) {
+	mocket.Catcher.Reset()
+	setPaymentMatch("fake_pi_1", StatusPending)
+	saved := setPaymentUpdate()
+	settled := recordSettled()
+
+	w, r := makeRequest(`{"intent_id": "fake_pi_1", "status": "failed"}`, nil)
+	handleWebhook(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+	if len(*saved) != 1 || (*saved)[0] != StatusFailed {
+		t.Error("Expected failed payment to be saved, got:", *saved)
+	}
+	if len(*settled) != 1 || (*settled)[0].Status != StatusFailed {
+		t.Error("Expected settled handlers to be told the payment failed, got:", *settled)
+	}
+}
+
+func TestWebhookInvalid(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	w, r := makeRequest(`{"status": "succeeded"}`, nil)
+	handleWebhook(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestWebhookPaidAfterExpiry(t *testing.T) {
+	mocket.Catcher.Reset()
+	intent, _ := provider.CreateIntent(1500, "EUR", "Invoice 1")
+	setPaymentMatch(intent.ID, StatusExpired)
+	saved := setPaymentUpdate()
+	settled := recordSettled()
+
+	w, r := makeRequest(`{"intent_id": "`+intent.ID+`", "status": "succeeded"}`, nil)
+	handleWebhook(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+	if len(*saved) != 1 || (*saved)[0] != StatusRefunding {
+		t.Error("Expected payment arriving after expiry to be refunded, got:", *saved)
+	}
+	if len(*settled) != 0 {
+		t.Error("Expected no settled handlers to be called for an already given up payment, got:", *settled)
+	}
+}
+
+func TestRefundDue(t *testing.T) {
+	mocket.Catcher.Reset()
+	intent, _ := provider.CreateIntent(1500, "EUR", "Invoice 1")
+	provider.Confirm(intent.ID)
+	setPaymentMatch(intent.ID, StatusRefunding)
+	saved := setPaymentUpdate()
+
+	refunded, err := RefundDue()
+	if err != nil {
+		t.Error("Error refunding payments:", err)
+	}
+
+	if refunded != 1 || len(*saved) != 1 || (*saved)[0] != StatusRefunded {
+		t.Error("Expected the payment to be refunded, got:", refunded, *saved)
+	}
+	err = provider.Refund(intent.ID, 1500)
+	if err == nil {
+		t.Error("Expected the intent to have been refunded at the provider")
+	}
+}
+
+func TestOpenFailing(t *testing.T) {
+	defer SetProvider(NewFakeProvider())
+	mocket.Catcher.Reset()
+	setPaymentMatch("", StatusPending)
+	saved := setPaymentUpdate()
+	settled := recordSettled()
+	SetProvider(failingProvider{})
+
+	payment := Payment{ID: 3, InvoiceID: 7, Amount: 1500, Currency: "EUR", Status: StatusPending}
+	err := Open(&payment)
+	if err == nil {
+		t.Error("Expected opening the payment to fail with the provider")
+	}
+
+	if len(*saved) != 1 || (*saved)[0] != StatusFailed {
+		t.Error("Expected payment which couldn't be opened to be given up, got:", *saved)
+	}
+	if len(*settled) != 1 || (*settled)[0].Status != StatusFailed {
+		t.Error("Expected settled handlers to release the payment, got:", *settled)
+	}
+}
+
+func TestExpireOverdue(t *testing.T) {
+	mocket.Catcher.Reset()
+	setPaymentMatch("fake_pi_1", StatusPending)
+	saved := setPaymentUpdate()
+	settled := recordSettled()
+
+	expired, err := ExpireOverdue(time.Now().UTC())
+	if err != nil {
+		t.Error("Error expiring overdue payments:", err)
+	}
+
+	if expired != 1 || len(*saved) != 1 || (*saved)[0] != StatusExpired {
+		t.Error("Expected the overdue payment to expire, got:", expired, *saved)
+	}
+	if len(*settled) != 1 || (*settled)[0].Status != StatusExpired {
+		t.Error("Expected settled handlers to release the expired payment, got:", *settled)
+	}
+}
+
+func TestSettlingHandlersFirst(t *testing.T) {
+	defer func() { settlingHandlers = nil }()
+	mocket.Catcher.Reset()
+	setPaymentMatch("fake_pi_1", StatusPending)
+	setPaymentUpdate()
+	settled := recordSettled()
+
+	var settledBefore []int
+	settlingHandlers = nil
+	OnSettling(func(tx *gorm.DB, payment Payment) error {
+		settledBefore = append(settledBefore, len(*settled))
+		return nil
+	})
+
+	_, err := ExpireOverdue(time.Now().UTC())
+	if err != nil {
+		t.Error("Error expiring overdue payments:", err)
+	}
+
+	if len(settledBefore) != 1 || settledBefore[0] != 0 || len(*settled) != 1 {
+		t.Error("Expected settling handlers to run before the payment was settled, got:", settledBefore, *settled)
+	}
+}
+
+// Provider which is down, failing everything
+type failingProvider struct {
+	noProvider
+}
+
+func (failingProvider) CreateIntent(amount uint, currency string, reference string) (Intent, error) {
+	return Intent{}, errors.New("provider unavailable")
+}
+
+func makeRequest(body string, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	r := httptest.NewRequest("POST", "/payments", strings.NewReader(body))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,137 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// PaymentProvider online payment service payments are made through
+type PaymentProvider interface {
+	// Name of the provider stored with its payments
+	Name() string
+	// CreateIntent start a payment of amount cents, described to the payer with reference
+	CreateIntent(amount uint, currency string, reference string) (Intent, error)
+	// Confirm complete the payment of intent, returning its resulting status
+	Confirm(intentID string) (Intent, error)
+	// Refund pay amount cents of a succeeded intent back to the payer
+	Refund(intentID string, amount uint) error
+	// ParseWebhook verify and decode a webhook request sent by the provider
+	ParseWebhook(r *http.Request) (Event, error)
+}
+
+// ErrNoProvider is returned when paying online without a payment provider set
+var ErrNoProvider = errors.New("Online payments are not available")
+
+// Provider in use until one is set, failing every payment
+type noProvider struct{}
+
+func (noProvider) Name() string {
+	return "none"
+}
+
+func (noProvider) CreateIntent(amount uint, currency string, reference string) (Intent, error) {
+	return Intent{}, ErrNoProvider
+}
+
+func (noProvider) Confirm(intentID string) (Intent, error) {
+	return Intent{}, ErrNoProvider
+}
+
+func (noProvider) Refund(intentID string, amount uint) error {
+	return ErrNoProvider
+}
+
+func (noProvider) ParseWebhook(r *http.Request) (Event, error) {
+	return Event{}, ErrNoProvider
+}
+
+// ErrNoSuchIntent is returned by the fake provider for intents it hasn't created
+var ErrNoSuchIntent = errors.New("No such payment intent")
+
+// FakeProvider payment provider keeping intents in memory, for running the studio locally
+// and in tests. Payments succeed when confirmed, and webhooks are accepted unsigned.
+type FakeProvider struct {
+	mutex   sync.Mutex
+	intents map[string]*Intent
+	next    uint64
+}
+
+// NewFakeProvider fake provider without intents
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{intents: map[string]*Intent{}}
+}
+
+// Name of the fake provider
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateIntent start a pending payment
+func (p *FakeProvider) CreateIntent(amount uint, currency string, reference string) (Intent, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.next++
+	intent := &Intent{
+		ID:           fmt.Sprintf("fake_pi_%d", p.next),
+		Status:       StatusPending,
+		ClientSecret: fmt.Sprintf("fake_pi_%d_secret", p.next),
+	}
+	p.intents[intent.ID] = intent
+	return *intent, nil
+}
+
+// Confirm mark pending payment succeeded
+func (p *FakeProvider) Confirm(intentID string) (Intent, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Intent{}, ErrNoSuchIntent
+	}
+
+	if intent.Status == StatusPending {
+		intent.Status = StatusSucceeded
+	}
+	return *intent, nil
+}
+
+// Refund mark succeeded payment refunded
+func (p *FakeProvider) Refund(intentID string, amount uint) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return ErrNoSuchIntent
+	}
+	if intent.Status != StatusSucceeded {
+		return &TransitionError{From: intent.Status, To: StatusRefunded}
+	}
+
+	intent.Status = StatusRefunded
+	return nil
+}
+
+// ParseWebhook decode webhook with intent_id and status in a JSON body
+func (p *FakeProvider) ParseWebhook(r *http.Request) (Event, error) {
+	var event Event
+	err := json.NewDecoder(r.Body).Decode(&event)
+	if err != nil {
+		return event, err
+	}
+	if event.IntentID == "" || event.Status == "" {
+		return event, errors.New("Missing intent_id or status in webhook")
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if intent, ok := p.intents[event.IntentID]; ok {
+		intent.Status = event.Status
+	}
+	return event, nil
+}
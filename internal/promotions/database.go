@@ -0,0 +1,267 @@
+package promotions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// ErrNoSuchClass is returned when making a discount code valid for a class which doesn't exist
+var ErrNoSuchClass = errors.New("No such class")
+
+// ErrCodeTaken is returned when adding a discount code which already exists
+var ErrCodeTaken = errors.New("Discount code already exists")
+
+// ErrNoSuchCode is returned when redeeming a discount code which doesn't exist
+var ErrNoSuchCode = errors.New("No such discount code")
+
+// ErrCodeNotValid is returned when redeeming a discount code outside its validity window
+var ErrCodeNotValid = errors.New("Discount code is not valid at this time")
+
+// ErrCodeUsedUp is returned when redeeming a discount code which has reached its maximum redemptions
+var ErrCodeUsedUp = errors.New("Discount code has been used up")
+
+// ErrCodeNotForClass is returned when redeeming a discount code for a class it isn't valid for
+var ErrCodeNotForClass = errors.New("Discount code is not valid for the class")
+
+// IsRedeemError tells if err is a reason the discount code given can't be redeemed
+func IsRedeemError(err error) bool {
+	return err == ErrNoSuchCode || err == ErrCodeNotValid || err == ErrCodeUsedUp || err == ErrCodeNotForClass
+}
+
+// Redeem discount code for a session of the class on date booked by member at price, inside
+// transaction tx. The code row is locked, so that concurrent redemptions can't exceed its
+// maximum, and the redemption is undone with the rest of the transaction.
+func Redeem(tx *gorm.DB, code string, memberID uint64, classID uint64, date time.Time, price uint) (*Redemption, error) {
+	var discountCode DiscountCode
+	err := tx.Set("gorm:query_option", "FOR UPDATE").Where("code = ?", NormaliseCode(code)).First(&discountCode).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrNoSuchCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	err = checkRedeemable(tx, &discountCode, classID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(&discountCode).Update("redemptions", discountCode.Redemptions+1).Error
+	if err != nil {
+		return nil, err
+	}
+
+	redemption := Redemption{
+		DiscountCodeID: discountCode.ID,
+		Code:           discountCode.Code,
+		MemberID:       memberID,
+		ClassID:        classID,
+		BookingDate:    date,
+		Discount:       discountCode.DiscountOn(price),
+		RedeemedAt:     now,
+	}
+	err = tx.Create(&redemption).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &redemption, nil
+}
+
+// Validate discount code could be redeemed for a session of the class now, inside transaction
+// tx, without redeeming it
+func Validate(tx *gorm.DB, code string, classID uint64) error {
+	var discountCode DiscountCode
+	err := tx.Where("code = ?", NormaliseCode(code)).First(&discountCode).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrNoSuchCode
+	}
+	if err != nil {
+		return err
+	}
+
+	return checkRedeemable(tx, &discountCode, classID, time.Now().UTC())
+}
+
+// Check discount code is valid at now, hasn't been used up and is valid for the class
+func checkRedeemable(tx *gorm.DB, discountCode *DiscountCode, classID uint64, now time.Time) error {
+	if !discountCode.ValidAt(now) {
+		return ErrCodeNotValid
+	}
+	if discountCode.MaxRedemptions != nil && discountCode.Redemptions >= *discountCode.MaxRedemptions {
+		return ErrCodeUsedUp
+	}
+
+	var restrictions, validFor uint
+	err := tx.Model(&DiscountCodeClass{}).Where("discount_code_id = ?", discountCode.ID).Count(&restrictions).Error
+	if err == nil && restrictions > 0 {
+		err = tx.Model(&DiscountCodeClass{}).Where("discount_code_id = ? AND class_id = ?", discountCode.ID, classID).Count(&validFor).Error
+		if err == nil && validFor == 0 {
+			return ErrCodeNotForClass
+		}
+	}
+	return err
+}
+
+// Release redemption redemptionID inside transaction tx, giving the redemption back to its
+// discount code. The code row is locked like when redeeming. A redemption already released
+// or of a code since removed is left alone.
+func Release(tx *gorm.DB, redemptionID uint64) error {
+	var redemption Redemption
+	err := tx.First(&redemption, redemptionID).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var discountCode DiscountCode
+	err = tx.Set("gorm:query_option", "FOR UPDATE").First(&discountCode, redemption.DiscountCodeID).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	if err == nil && discountCode.Redemptions > 0 {
+		err = tx.Model(&discountCode).Update("redemptions", discountCode.Redemptions-1).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Delete(&redemption).Error
+}
+
+// Load ids of the classes discount codes are valid for
+func loadClassIDs(tx *gorm.DB, codes []DiscountCode) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	codeIDs := make([]uint64, len(codes))
+	for i := range codes {
+		codeIDs[i] = codes[i].ID
+		codes[i].ClassIDs = []uint64{}
+	}
+
+	var validFor []DiscountCodeClass
+	err := tx.Where("discount_code_id IN (?)", codeIDs).Order("class_id ASC").Find(&validFor).Error
+	if err != nil {
+		return err
+	}
+
+	for _, valid := range validFor {
+		for i := range codes {
+			if codes[i].ID == valid.DiscountCodeID {
+				codes[i].ClassIDs = append(codes[i].ClassIDs, valid.ClassID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Insert or update discount code with the classes it's valid for in one transaction. An existing
+// code is locked first, so that redemptions made meanwhile aren't overwritten.
+func (db *Database) saveDiscountCode(code *DiscountCode) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if code.ID != 0 {
+		var stored DiscountCode
+		err := tx.Set("gorm:query_option", "FOR UPDATE").First(&stored, code.ID).Error
+		if err != nil {
+			return err
+		}
+		code.Redemptions = stored.Redemptions
+	}
+
+	if len(code.ClassIDs) > 0 {
+		var found uint
+		err := tx.Model(&classes.Class{}).Where("id IN (?)", code.ClassIDs).Count(&found).Error
+		if err != nil {
+			return err
+		}
+		if found != uint(len(code.ClassIDs)) {
+			return ErrNoSuchClass
+		}
+	}
+
+	err := tx.Save(code).Error
+	if helpers.IsDuplicateEntry(err) {
+		return ErrCodeTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.Where("discount_code_id = ?", code.ID).Delete(&DiscountCodeClass{}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, classID := range code.ClassIDs {
+		err = tx.Create(&DiscountCodeClass{DiscountCodeID: code.ID, ClassID: classID}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Get discount code from database by id in request and handle error situations
+func (db *Database) getDiscountCodeFromReq(w http.ResponseWriter, r *http.Request) (*DiscountCode, error) {
+	var code DiscountCode
+	vars := mux.Vars(r)
+	codeID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested discount code id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid discount code ID")
+		return nil, err
+	}
+
+	err = db.First(&code, codeID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested discount code by id %d does not exist", codeID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Discount code does not exist")
+		} else {
+			log.Error("Error fetching discount code from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+
+	codes := []DiscountCode{code}
+	err = loadClassIDs(db.DB, codes)
+	if err != nil {
+		log.Error("Error fetching classes of discount code from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return nil, err
+	}
+	return &codes[0], nil
+}
@@ -0,0 +1,138 @@
+package promotions
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Kinds of discount
+const (
+	KindPercent = "percent"
+	KindFixed   = "fixed"
+)
+
+// DiscountCode code taking a percentage or a fixed amount in cents off the price of a session
+type DiscountCode struct {
+	ID     uint64 `gorm:"primary_key" json:"id"`
+	Code   string `gorm:"unique_index" json:"code"`
+	Kind   string `json:"kind"`
+	Amount uint   `json:"amount"`
+	// Time window the code can be redeemed in, open ended when nil
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+	// How many times the code can be redeemed, or nil for no limit
+	MaxRedemptions *uint `json:"max_redemptions"`
+	Redemptions    uint  `json:"redemptions"`
+	// Classes the code is valid for, stored in discount_code_classes. Codes valid for all classes have none.
+	ClassIDs []uint64 `gorm:"-" json:"class_ids"`
+}
+
+// DiscountCodeClass class a discount code is valid for
+type DiscountCodeClass struct {
+	DiscountCodeID uint64 `gorm:"primary_key;auto_increment:false"`
+	ClassID        uint64 `gorm:"primary_key;auto_increment:false"`
+}
+
+// Redemption of a discount code for a session booked by a member
+type Redemption struct {
+	ID             uint64    `gorm:"primary_key" json:"id"`
+	DiscountCodeID uint64    `json:"discount_code_id"`
+	Code           string    `json:"code"`
+	MemberID       uint64    `json:"member_id"`
+	ClassID        uint64    `json:"class_id"`
+	BookingDate    time.Time `gorm:"type:date" json:"booking_date"`
+	// Amount taken off the price, in cents
+	Discount   uint      `json:"discount"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// NormaliseCode codes are matched case insensitively and stored in upper case
+func NormaliseCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// DiscountOn amount the code takes off price, never more than the price itself
+func (c *DiscountCode) DiscountOn(price uint) uint {
+	discount := c.Amount
+	if c.Kind == KindPercent {
+		discount = uint(math.Round(float64(price) * float64(c.Amount) / 100))
+	}
+	if discount > price {
+		return price
+	}
+	return discount
+}
+
+// ValidAt tells if the code can be redeemed at time at
+func (c *DiscountCode) ValidAt(at time.Time) bool {
+	if c.ValidFrom != nil && at.Before(*c.ValidFrom) {
+		return false
+	}
+	return c.ValidUntil == nil || !at.After(*c.ValidUntil)
+}
+
+// UnmarshalJSON to validate discount code and strip ID and read-only fields from requests
+func (c *DiscountCode) UnmarshalJSON(data []byte) error {
+	type Alias DiscountCode
+	aux := &struct {
+		ID          uint64 `gorm:"-" sql:"-" json:"id"`
+		Redemptions uint   `gorm:"-" sql:"-" json:"redemptions"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	c.Code = NormaliseCode(c.Code)
+	if c.Code == "" || strings.ContainsAny(c.Code, " \t") {
+		return errors.New("Invalid code in payload, expected a code without spaces")
+	}
+
+	switch c.Kind {
+	case KindPercent:
+		if c.Amount < 1 || c.Amount > 100 {
+			return errors.New("Invalid amount in payload, percentage must be from 1 to 100")
+		}
+	case KindFixed:
+		if c.Amount < 1 {
+			return errors.New("Invalid amount in payload, must to be over 0")
+		}
+	default:
+		return errors.New("Invalid kind in payload, expected percent or fixed")
+	}
+
+	if c.ValidFrom != nil && c.ValidUntil != nil && c.ValidUntil.Before(*c.ValidFrom) {
+		return errors.New("Invalid valid_until in payload, must not be before valid_from")
+	}
+
+	seen := map[uint64]bool{}
+	classIDs := []uint64{}
+	for _, classID := range c.ClassIDs {
+		if !seen[classID] {
+			seen[classID] = true
+			classIDs = append(classIDs, classID)
+		}
+	}
+	c.ClassIDs = classIDs
+
+	return nil
+}
+
+// MarshalJSON to output the booking date without time
+func (r *Redemption) MarshalJSON() ([]byte, error) {
+	type Alias Redemption
+	return json.Marshal(&struct {
+		BookingDate string `json:"booking_date"`
+		*Alias
+	}{
+		BookingDate: r.BookingDate.Format("2006-01-02"),
+		Alias:       (*Alias)(r),
+	})
+}
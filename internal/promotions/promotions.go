@@ -0,0 +1,143 @@
+package promotions
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getDiscountCodes(w http.ResponseWriter, r *http.Request) {
+	var codes []DiscountCode
+	err := db.Order("code ASC").Find(&codes).Error
+	if err == nil {
+		err = loadClassIDs(db.DB, codes)
+	}
+
+	if err != nil {
+		log.Error("Error fetching discount codes from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&codes)
+}
+
+// Respond to errors saving a discount code
+func respondSaveError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrNoSuchClass:
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+	case ErrCodeTaken:
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+	default:
+		log.Error("Error saving discount code to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+	}
+}
+
+func addDiscountCode(w http.ResponseWriter, r *http.Request) {
+	var code DiscountCode
+	err := json.NewDecoder(r.Body).Decode(&code)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new discount code: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for discount code")
+		return
+	}
+
+	err = db.saveDiscountCode(&code)
+	if err != nil {
+		respondSaveError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&code)
+}
+
+func getDiscountCode(w http.ResponseWriter, r *http.Request) {
+	code, err := db.getDiscountCodeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&code)
+}
+
+func updateDiscountCode(w http.ResponseWriter, r *http.Request) {
+	code, err := db.getDiscountCodeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&code)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating discount code: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for discount code")
+		return
+	}
+
+	err = db.saveDiscountCode(code)
+	if err != nil {
+		respondSaveError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&code)
+}
+
+func deleteDiscountCode(w http.ResponseWriter, r *http.Request) {
+	code, err := db.getDiscountCodeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	if code.Redemptions > 0 {
+		helpers.ResponseJSON(w, http.StatusConflict, "Discount code has been redeemed and it can't be removed, end its validity instead")
+		return
+	}
+
+	err = db.Delete(&code).Error
+	if err == nil {
+		err = db.Where("discount_code_id = ?", code.ID).Delete(&DiscountCodeClass{}).Error
+	}
+	if err != nil {
+		log.Error("Error deleting discount code from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Discount code removed")
+}
+
+func getRedemptions(w http.ResponseWriter, r *http.Request) {
+	code, err := db.getDiscountCodeFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var redemptions []Redemption
+	err = db.Where("discount_code_id = ?", code.ID).Order("redeemed_at ASC").Find(&redemptions).Error
+	if err != nil {
+		log.Error("Error fetching redemptions of discount code from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&redemptions)
+}
+
+// Routes set routes for /discount-codes
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&DiscountCode{}, &DiscountCodeClass{}, &Redemption{})
+
+	router.HandleFunc("", getDiscountCodes).Methods("GET")
+	router.HandleFunc("", addDiscountCode).Methods("POST")
+	router.HandleFunc("/{id}", getDiscountCode).Methods("GET")
+	router.HandleFunc("/{id}", updateDiscountCode).Methods("PUT")
+	router.HandleFunc("/{id}", deleteDiscountCode).Methods("DELETE")
+	router.HandleFunc("/{id}/redemptions", getRedemptions).Methods("GET")
+}
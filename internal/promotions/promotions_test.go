@@ -0,0 +1,182 @@
+package promotions
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+// Mock discount code SUMMER with reply fields on top of a 20% discount
+func setCodeMatch(fields map[string]interface{}) {
+	reply := map[string]interface{}{
+		"id":     2,
+		"code":   "SUMMER",
+		"kind":   KindPercent,
+		"amount": 20,
+	}
+	for key, value := range fields {
+		reply[key] = value
+	}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "discount_codes"  WHERE (code = SUMMER)`).WithReply([]map[string]interface{}{reply})
+}
+
+func TestDiscountOn(t *testing.T) {
+	percent := DiscountCode{Kind: KindPercent, Amount: 15}
+	if percent.DiscountOn(1250) != 188 {
+		t.Errorf("Expected 15%% of 12.50 to be 1.88, got %d", percent.DiscountOn(1250))
+	}
+
+	fixed := DiscountCode{Kind: KindFixed, Amount: 2000}
+	if fixed.DiscountOn(1500) != 1500 {
+		t.Errorf("Expected fixed discount not to exceed the price, got %d", fixed.DiscountOn(1500))
+	}
+}
+
+func TestAddDiscountCode(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "classes"  WHERE (id IN (1))`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "discount_codes"`).WithID(2)
+	var validFor []interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "discount_code_classes"`).WithCallback(func(query string, args []driver.NamedValue) {
+		validFor = append(validFor, args[1].Value)
+	})
+
+	w, r := makeRequest(map[string]interface{}{"code": " summer ", "kind": "percent", "amount": 20, "max_redemptions": 100, "redemptions": 50, "class_ids": []int{1}}, nil)
+	addDiscountCode(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var code DiscountCode
+	err = json.Unmarshal(body, &code)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if code.Code != "SUMMER" || code.Redemptions != 0 || *code.MaxRedemptions != 100 {
+		t.Error("Received discount code didn't match expectations:", code)
+	}
+	if len(validFor) != 1 || validFor[0] != int64(1) {
+		t.Error("Expected discount code to be made valid for the class, got:", validFor)
+	}
+}
+
+func TestAddDiscountCodeInvalid(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	for _, payload := range []map[string]interface{}{
+		{"code": "SUMMER", "kind": "percent", "amount": 120},
+		{"code": "SUMMER", "kind": "free", "amount": 10},
+		{"code": "SUMMER SALE", "kind": "fixed", "amount": 500},
+		{"code": "SUMMER", "kind": "fixed", "amount": 500, "valid_from": "2019-08-01T00:00:00Z", "valid_until": "2019-07-01T00:00:00Z"},
+	} {
+		w, r := makeRequest(payload, nil)
+		addDiscountCode(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected HTTP status 400 for %v, got %d instead", payload, w.Code)
+		}
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	mocket.Catcher.Reset()
+	setCodeMatch(map[string]interface{}{"max_redemptions": 10, "redemptions": 3})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "discount_code_classes"  WHERE (discount_code_id = 2)`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "discount_code_classes"  WHERE (discount_code_id = 2 AND class_id = 1)`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+	var redeemed []interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "discount_codes"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		redeemed = append(redeemed, args[0].Value)
+	})
+
+	redemption, err := Redeem(db.DB, "summer", 5, 1, time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC), 1500)
+	if err != nil {
+		t.Error("Error redeeming discount code:", err)
+	}
+
+	if redemption == nil || redemption.Discount != 300 || redemption.Code != "SUMMER" {
+		t.Error("Redemption didn't match expectations:", redemption)
+	}
+	if len(redeemed) != 1 || redeemed[0] != int64(4) {
+		t.Error("Expected redemption count to go up, got:", redeemed)
+	}
+}
+
+func TestRedeemRejected(t *testing.T) {
+	date := time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC)
+
+	mocket.Catcher.Reset()
+	setCodeMatch(map[string]interface{}{"max_redemptions": 10, "redemptions": 10})
+	_, err := Redeem(db.DB, "SUMMER", 5, 1, date, 1500)
+	if err != ErrCodeUsedUp {
+		t.Error("Expected used up code to be rejected, got:", err)
+	}
+
+	mocket.Catcher.Reset()
+	setCodeMatch(map[string]interface{}{"valid_until": time.Now().UTC().Add(-time.Hour)})
+	_, err = Redeem(db.DB, "SUMMER", 5, 1, date, 1500)
+	if err != ErrCodeNotValid {
+		t.Error("Expected expired code to be rejected, got:", err)
+	}
+
+	mocket.Catcher.Reset()
+	setCodeMatch(nil)
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "discount_code_classes"  WHERE (discount_code_id = 2)`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "discount_code_classes"  WHERE (discount_code_id = 2 AND class_id = 1)`).WithReply([]map[string]interface{}{{"count(*)": 0}})
+	_, err = Redeem(db.DB, "SUMMER", 5, 1, date, 1500)
+	if err != ErrCodeNotForClass {
+		t.Error("Expected code to be rejected for other classes, got:", err)
+	}
+
+	mocket.Catcher.Reset()
+	_, err = Redeem(db.DB, "WINTER", 5, 1, date, 1500)
+	if err != ErrNoSuchCode {
+		t.Error("Expected unknown code to be rejected, got:", err)
+	}
+}
+
+func TestDeleteRedeemedCode(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "discount_codes"`).WithReply([]map[string]interface{}{{"id": 2, "code": "SUMMER", "redemptions": 1}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	deleteDiscountCode(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/discount-codes", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,38 @@
+package reminders
+
+import (
+	"github.com/teeaa/studio/internal/notify"
+)
+
+// Channel reminds members of their sessions. Remind returning nil counts the reminder as
+// sent, so it isn't sent again.
+type Channel interface {
+	Name() string
+	Remind(due Due) error
+}
+
+// EmailChannel reminds members by email, once notifications are set up. Members without
+// an email address are skipped.
+type EmailChannel struct{}
+
+// Name of the channel, recorded with sent reminders
+func (EmailChannel) Name() string {
+	return "email"
+}
+
+// Remind send reminder email of the session to the member, waiting for the SMTP server to
+// accept it so that a reminder which couldn't be sent is tried again
+func (EmailChannel) Remind(due Due) error {
+	if due.Member.Email == nil {
+		return nil
+	}
+
+	return notify.SendNow(notify.EventSessionReminder, *due.Member.Email, notify.BookingNotice{
+		MemberName: due.Member.Name,
+		ClassName:  due.Class.Name,
+		BookingID:  due.Booking.ID,
+		Date:       due.Session.Date.Format("2006-01-02"),
+		StartTime:  due.Class.StartTime,
+		Status:     due.Booking.Status,
+	})
+}
@@ -0,0 +1,195 @@
+package reminders
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/members"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// Setup set db and create the table of sent reminders
+func Setup(gormDB *gorm.DB) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Reminder{})
+}
+
+// How long before the session starts members are reminded of it
+var window = 24 * time.Hour
+
+// How long a claimed reminder may go without its result being recorded before it's claimed again,
+// for runs which stopped while sending. Well above the time sending is allowed to take.
+const claimTimeout = 10 * time.Minute
+
+// Channel reminders are sent through, or nil when reminders are off
+var channel Channel
+
+// SetWindow set how long before the session starts members are reminded of it
+func SetWindow(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("Reminder window must be positive")
+	}
+	window = d
+	return nil
+}
+
+// SetChannel set the channel reminders are sent through. Reminders are off until one is set.
+func SetChannel(c Channel) {
+	channel = c
+}
+
+// Enabled tells whether a channel has been set to send reminders through
+func Enabled() bool {
+	return channel != nil
+}
+
+// SendDue remind members of their confirmed bookings whose session starts within the window
+// from now and who haven't been reminded yet, returning how many reminders were sent. The
+// reminders are claimed first and sent outside of any transaction. A failed reminder is
+// tried again on the next run. Nothing is sent while no channel is set.
+func SendDue(now time.Time) (int, error) {
+	if channel == nil {
+		return 0, nil
+	}
+
+	due, err := db.findDue(now)
+	if err != nil {
+		return 0, err
+	}
+
+	claimed, err := db.claim(due, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, d := range claimed {
+		err = channel.Remind(d)
+		if err != nil {
+			log.Errorf("Error reminding member %d of booking %d: %s", d.Member.ID, d.Booking.ID, err)
+		} else {
+			sent++
+		}
+
+		recordErr := db.record(d, now, err)
+		if recordErr != nil {
+			log.Errorf("Error recording reminder of booking %d: %s", d.Booking.ID, recordErr)
+		}
+	}
+
+	return sent, nil
+}
+
+// Find bookings due a reminder. Dates are matched with a day to spare on both sides,
+// as sessions start in the timezones of their classes.
+func (db *Database) findDue(now time.Time) ([]Due, error) {
+	var booked []bookings.Booking
+	err := db.Where("status = ? AND booking_date BETWEEN ? AND ? AND id NOT IN (SELECT booking_id FROM reminders WHERE sent_at IS NOT NULL OR claimed_at >= ?)",
+		bookings.StatusConfirmed, classes.DateOf(now).AddDate(0, 0, -1), classes.DateOf(now.Add(window)).AddDate(0, 0, 1), now.Add(-claimTimeout)).
+		Order("booking_date ASC").
+		Find(&booked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	due := []Due{}
+	calendars := map[uint64]classes.Calendar{}
+	classesByID := map[uint64]classes.Class{}
+	for _, booking := range booked {
+		class, ok := classesByID[booking.ClassID]
+		if !ok {
+			class, err = classes.GetClassByID(booking.ClassID)
+			if gorm.IsRecordNotFoundError(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			classesByID[booking.ClassID] = class
+
+			calendars[booking.ClassID], err = classes.GetCalendar(db.DB, class.ID, classes.DateOf(now).AddDate(0, 0, -1), classes.DateOf(now.Add(window)).AddDate(0, 0, 1))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Sessions which were cancelled or fall on a blackout aren't reminded of
+		if class.CheckDate(booking.BookingDate, calendars[booking.ClassID]) != nil {
+			continue
+		}
+
+		session := class.SessionOn(booking.BookingDate)
+		if session.Start.IsZero() || !session.Start.After(now) || session.Start.After(now.Add(window)) {
+			continue
+		}
+
+		member, err := members.GetMemberByID(booking.MemberID)
+		if err != nil {
+			return nil, err
+		}
+
+		due = append(due, Due{Booking: booking, Member: member, Class: class, Session: session})
+	}
+
+	return due, nil
+}
+
+// Claim reminders of the due bookings in one short transaction, so that none is held open while
+// they're sent. A booking claimed by a concurrent run fails on the unique index and is left to
+// that run, unless its claim has been abandoned for longer than claimTimeout. Returns the
+// bookings claimed.
+func (db *Database) claim(due []Due, now time.Time) ([]Due, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	claimed := []Due{}
+	for _, d := range due {
+		reminder := Reminder{BookingID: d.Booking.ID, Channel: channel.Name(), ClaimedAt: now}
+		err := tx.Create(&reminder).Error
+		if helpers.IsDuplicateEntry(err) {
+			abandoned := tx.Model(&Reminder{}).
+				Where("booking_id = ? AND sent_at IS NULL AND claimed_at < ?", d.Booking.ID, now.Add(-claimTimeout)).
+				Updates(map[string]interface{}{"channel": channel.Name(), "claimed_at": now})
+			err = abandoned.Error
+			if err == nil && abandoned.RowsAffected == 0 {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		claimed = append(claimed, d)
+	}
+
+	return claimed, tx.Commit().Error
+}
+
+// Record the outcome of sending the claimed reminder of due. A reminder which couldn't be sent
+// is released to be tried again on the next run.
+func (db *Database) record(due Due, now time.Time, sendErr error) error {
+	if sendErr != nil {
+		return db.Where("booking_id = ? AND sent_at IS NULL", due.Booking.ID).Delete(&Reminder{}).Error
+	}
+
+	return db.Model(&Reminder{}).Where("booking_id = ?", due.Booking.ID).Update("sent_at", now).Error
+}
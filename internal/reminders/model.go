@@ -0,0 +1,29 @@
+package reminders
+
+import (
+	"time"
+
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/members"
+)
+
+// Reminder representation of reminders.reminders, recording the reminder of a booking. The
+// reminder is claimed before it's sent, and SentAt is set once it has been. A booking is
+// reminded of only once, which the unique index enforces.
+type Reminder struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	BookingID uint64    `gorm:"unique_index" json:"booking_id"`
+	Channel   string    `json:"channel"`
+	ClaimedAt time.Time `json:"claimed_at"`
+	// Nil while the reminder is being sent
+	SentAt *time.Time `json:"sent_at"`
+}
+
+// Due booking to remind of its session, with the member to remind and the class booked
+type Due struct {
+	Booking bookings.Booking
+	Member  members.Member
+	Class   classes.Class
+	Session classes.Session
+}
@@ -0,0 +1,121 @@
+package reminders
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/members"
+)
+
+// Channel recording the bookings it reminded of, failing with err when it's set
+type fakeChannel struct {
+	reminded []uint64
+	err      error
+}
+
+func (c *fakeChannel) Name() string {
+	return "fake"
+}
+
+func (c *fakeChannel) Remind(due Due) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.reminded = append(c.reminded, due.Booking.ID)
+	return nil
+}
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+	classes.SetupExternally(classes.Database{DB: gormDB})
+	members.SetupExternally(members.Database{DB: gormDB})
+}
+
+// Mock bookings of a Monday and Wednesday class starting at 18:30 UTC, on Monday 15 and Wednesday 17 July
+func setBookingsMatch() {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"`).WithReply([]map[string]interface{}{
+		{"id": 7, "member_id": 5, "class_id": 1, "booking_date": time.Date(2019, 7, 15, 0, 0, 0, 0, time.UTC), "status": "confirmed"},
+		{"id": 8, "member_id": 5, "class_id": 1, "booking_date": time.Date(2019, 7, 17, 0, 0, 0, 0, time.UTC), "status": "confirmed"},
+	})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"`).WithReply([]map[string]interface{}{{
+		"id":               1,
+		"name":             "Salsa basics",
+		"start_date":       time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":         time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"weekdays":         "MO,WE",
+		"start_time":       "18:30",
+		"duration_minutes": 60,
+		"timezone":         "UTC",
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"`).WithReply([]map[string]interface{}{{"id": 5, "name": "Anna", "email": "anna@example.com"}})
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "reminders"`).WithID(1)
+}
+
+func TestSendDue(t *testing.T) {
+	setup()
+	setBookingsMatch()
+	var recorded []interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "reminders" SET "sent_at"`).WithCallback(func(query string, args []driver.NamedValue) {
+		recorded = append(recorded, args[1].Value)
+	})
+	fake := &fakeChannel{}
+	SetChannel(fake)
+
+	sent, err := SendDue(time.Date(2019, 7, 14, 20, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error sending reminders:", err)
+	}
+
+	if sent != 1 || len(fake.reminded) != 1 || fake.reminded[0] != 7 {
+		t.Errorf("Expected only the session within 24 hours to be reminded of, sent %d: %v", sent, fake.reminded)
+	}
+	if len(recorded) != 1 || recorded[0] != int64(7) {
+		t.Error("Expected the reminder to be recorded sent, got:", recorded)
+	}
+}
+
+func TestSendDueCancelledSession(t *testing.T) {
+	setBookingsMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "class_exceptions"`).WithReply([]map[string]interface{}{{"id": 3, "class_id": 1, "date": time.Date(2019, 7, 15, 0, 0, 0, 0, time.UTC), "reason": "Public holiday"}})
+	fake := &fakeChannel{}
+	SetChannel(fake)
+
+	sent, err := SendDue(time.Date(2019, 7, 14, 20, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error sending reminders:", err)
+	}
+
+	if sent != 0 || len(fake.reminded) != 0 {
+		t.Error("Expected cancelled session not to be reminded of, got:", fake.reminded)
+	}
+}
+
+func TestSendDueFailed(t *testing.T) {
+	setBookingsMatch()
+	released := false
+	mocket.Catcher.NewMock().WithQuery(`DELETE FROM "reminders"`).WithCallback(func(query string, args []driver.NamedValue) {
+		released = true
+	})
+	SetChannel(&fakeChannel{err: errors.New("Channel is down")})
+
+	sent, err := SendDue(time.Date(2019, 7, 14, 20, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error sending reminders:", err)
+	}
+
+	if sent != 0 {
+		t.Errorf("Expected failed reminder not to count as sent, got %d", sent)
+	}
+	if !released {
+		t.Error("Expected failed reminder to be released to be tried again")
+	}
+}
@@ -0,0 +1,77 @@
+package rooms
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// ErrHasClasses is returned when removing a room classes are still held in
+var ErrHasClasses = errors.New("Classes are held in the room and it can't be removed")
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// GetRoomByID get room inside transaction tx
+func GetRoomByID(tx *gorm.DB, roomID uint64) (Room, error) {
+	var room Room
+	err := tx.First(&room, roomID).Error
+
+	if err != nil {
+		return Room{}, err
+	}
+
+	return room, nil
+}
+
+// LockRoomByID get room inside transaction tx and lock its row until the transaction ends,
+// so that classes are scheduled in the room one at a time
+func LockRoomByID(tx *gorm.DB, roomID uint64) (Room, error) {
+	return GetRoomByID(tx.Set("gorm:query_option", "FOR UPDATE"), roomID)
+}
+
+// Count classes held in room. Classes build on rooms, so they are counted straight from their table.
+func (db *Database) countClasses(roomID uint64) (uint, error) {
+	var count uint
+	err := db.Table("classes").Where("room_id = ?", roomID).Count(&count).Error
+	return count, err
+}
+
+// Get room from database by id in request and handle error situations
+func (db *Database) getRoomFromReq(w http.ResponseWriter, r *http.Request) (*Room, error) {
+	var room Room
+	vars := mux.Vars(r)
+	roomID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested room id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid room ID")
+		return nil, err
+	}
+
+	err = db.First(&room, roomID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested room by id %d does not exist", roomID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Room does not exist")
+		} else {
+			log.Error("Error fetching room from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &room, nil
+}
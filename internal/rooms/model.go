@@ -0,0 +1,40 @@
+package rooms
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Room representation of rooms.rooms, a room of the studio classes are held in
+type Room struct {
+	ID       uint64 `gorm:"primary_key" json:"id"`
+	Name     string `json:"name"`
+	Capacity uint   `json:"capacity"`
+}
+
+// UnmarshalJSON to validate room and strip ID from requests
+func (r *Room) UnmarshalJSON(data []byte) error {
+	type Alias Room
+	aux := &struct {
+		ID uint64 `gorm:"-" sql:"-" json:"id"`
+		*Alias
+	}{
+		Alias: (*Alias)(r),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("Missing name in payload")
+	}
+	if r.Capacity < 1 {
+		return errors.New("Invalid capacity in payload, must to be over 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package rooms
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getRooms(w http.ResponseWriter, r *http.Request) {
+	var rooms []Room
+	err := db.Find(&rooms).Error
+
+	if err != nil {
+		log.Error("Error fetching rooms from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&rooms)
+}
+
+func addRoom(w http.ResponseWriter, r *http.Request) {
+	var room Room
+	err := json.NewDecoder(r.Body).Decode(&room)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new room: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for room")
+		return
+	}
+
+	err = db.Create(&room).Error
+	if err != nil {
+		log.Error("Error inserting room to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&room)
+}
+
+func getRoom(w http.ResponseWriter, r *http.Request) {
+	room, err := db.getRoomFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&room)
+}
+
+func updateRoom(w http.ResponseWriter, r *http.Request) {
+	room, err := db.getRoomFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&room)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating room: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for room")
+		return
+	}
+
+	err = db.Save(&room).Error
+	if err != nil {
+		log.Error("Error saving room to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&room)
+}
+
+func deleteRoom(w http.ResponseWriter, r *http.Request) {
+	room, err := db.getRoomFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	classes, err := db.countClasses(room.ID)
+	if err != nil {
+		log.Error("Error counting classes in room from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if classes > 0 {
+		helpers.ResponseJSON(w, http.StatusConflict, ErrHasClasses.Error())
+		return
+	}
+
+	err = db.Delete(&room).Error
+	if err != nil {
+		log.Error("Error deleting room from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Room removed")
+}
+
+// Routes set routes for /rooms
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Room{})
+
+	router.HandleFunc("", getRooms).Methods("GET")
+	router.HandleFunc("", addRoom).Methods("POST")
+	router.HandleFunc("/{id}", getRoom).Methods("GET")
+	router.HandleFunc("/{id}", updateRoom).Methods("PUT")
+	router.HandleFunc("/{id}", deleteRoom).Methods("DELETE")
+}
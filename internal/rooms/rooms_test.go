@@ -0,0 +1,119 @@
+package rooms
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+func TestAddRoom(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset().NewMock().WithQuery(`INSERT  INTO "rooms"`).WithID(2)
+
+	w, r := makeRequest(map[string]interface{}{"id": 7, "name": " Studio A ", "capacity": 25}, nil)
+	addRoom(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var room map[string]interface{}
+	err = json.Unmarshal(body, &room)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if room["id"] != float64(2) || room["name"] != "Studio A" || room["capacity"] != float64(25) {
+		t.Error("Received room didn't match expectations:", room)
+	}
+}
+
+func TestAddRoomWithoutCapacity(t *testing.T) {
+	w, r := makeRequest(map[string]interface{}{"name": "Studio A"}, nil)
+	addRoom(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestGetRoom(t *testing.T) {
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "rooms"  WHERE ("rooms"."id" = 2)`).WithReply([]map[string]interface{}{{
+		"id":       2,
+		"name":     "Studio A",
+		"capacity": 25,
+	}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	getRoom(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var room Room
+	err = json.Unmarshal(body, &room)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if room.Name != "Studio A" || room.Capacity != 25 {
+		t.Error("Received room didn't match expectations:", room)
+	}
+}
+
+func TestDeleteRoomWithClasses(t *testing.T) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "classes"`).WithReply([]map[string]interface{}{{"count(*)": 1}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "2"})
+	deleteRoom(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestDeleteRoomNonExisting(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "345"})
+	deleteRoom(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/rooms", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,243 @@
+package waitlist
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/helpers"
+	"github.com/teeaa/studio/internal/notify"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// ErrSpotsLeft is returned when joining the waitlist of a session which can still be booked
+var ErrSpotsLeft = errors.New("Class still has free spots on the booking date, book it instead")
+
+// ErrAlreadyWaiting is returned when the member is already waiting for the session
+var ErrAlreadyWaiting = errors.New("Already on the waitlist for the booking date")
+
+// Get class id from request and handle error situations
+func getClassIDFromReq(w http.ResponseWriter, r *http.Request) (uint64, error) {
+	vars := mux.Vars(r)
+	classID, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		log.Warnf("Requested class id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid class ID")
+		return 0, err
+	}
+
+	return classID, nil
+}
+
+// Get waitlist entry of the requested class from database by id in request and handle error situations
+func (db *Database) getEntryFromReq(w http.ResponseWriter, r *http.Request) (*Entry, error) {
+	var entry Entry
+	classID, err := getClassIDFromReq(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := mux.Vars(r)
+	entryID, err := strconv.Atoi(vars["entryID"])
+	if err != nil {
+		log.Warnf("Requested waitlist entry id (%s) is not an integer: %s", vars["entryID"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid waitlist entry ID")
+		return nil, err
+	}
+
+	err = db.Where("class_id = ?", classID).First(&entry, entryID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested waitlist entry by id %d does not exist", entryID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Waitlist entry does not exist")
+		} else {
+			log.Error("Error fetching waitlist entry from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Add entry to the end of its session's waitlist. The class row is locked so that the
+// session can't get a free spot between checking it's full and joining the waitlist.
+func (db *Database) joinWaitlist(entry *Entry) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	class, err := classes.LockClassByID(tx, entry.ClassID)
+	if err != nil {
+		return err
+	}
+
+	booked, err := bookings.CountBooked(tx, entry.ClassID, entry.BookingDate, 0)
+	if err != nil {
+		return err
+	}
+
+	if booked < class.EffectiveCapacity() {
+		return ErrSpotsLeft
+	}
+
+	var waiting uint
+	err = tx.Model(&Entry{}).
+		Where("class_id = ? AND booking_date = ? AND member_id = ? AND status = ?", entry.ClassID, entry.BookingDate, entry.MemberID, StatusWaiting).
+		Count(&waiting).Error
+	if err != nil {
+		return err
+	}
+
+	if waiting > 0 {
+		return ErrAlreadyWaiting
+	}
+
+	entry.Status = StatusWaiting
+	err = tx.Create(entry).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Promote waiting entries of a session when a spot is freed, within the transaction which freed it.
+// Promoting is rolled back to a savepoint on errors so that the change freeing the spot still goes through.
+func promoteFreed(tx *gorm.DB, classID uint64, date time.Time) error {
+	err := tx.Exec("SAVEPOINT waitlist_promotion").Error
+	if err != nil {
+		return err
+	}
+
+	err = promoteNext(tx, classID, date)
+	if err != nil {
+		log.Errorf("Error promoting waitlist of class %d on %s: %s", classID, date.Format("2006-01-02"), err)
+		return tx.Exec("ROLLBACK TO SAVEPOINT waitlist_promotion").Error
+	}
+
+	return tx.Exec("RELEASE SAVEPOINT waitlist_promotion").Error
+}
+
+// Promote waiting entries of a session to bookings for as long as the session has free
+// spots. Entries of members without a valid membership or pass, or who would have to pay
+// for the session online, are skipped. Called with the class row locked.
+func promoteNext(tx *gorm.DB, classID uint64, date time.Time) error {
+	class, err := classes.LockClassByID(tx, classID)
+	if err != nil {
+		return err
+	}
+
+	for {
+		booked, err := bookings.CountBooked(tx, classID, date, 0)
+		if err != nil {
+			return err
+		}
+
+		if booked >= class.EffectiveCapacity() {
+			return nil
+		}
+
+		var entry Entry
+		err = tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("class_id = ? AND booking_date = ? AND status = ?", classID, date, StatusWaiting).
+			Order("id ASC").
+			First(&entry).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Member may have booked the session since joining, the entry then points to that booking
+		booking, err := bookings.FindBooking(tx, entry.MemberID, classID, date, 0)
+		created := gorm.IsRecordNotFoundError(err)
+		if created {
+			booking = &bookings.Booking{
+				MemberID:    entry.MemberID,
+				BookingDate: entry.BookingDate,
+				ClassID:     entry.ClassID,
+			}
+			err = bookings.CreateCoveredBooking(tx, booking)
+			if err == nil {
+				err = bookings.EmitCreatedTx(tx, booking)
+			}
+		}
+		// Nobody is around to pay for the session, the spot goes to the next member instead
+		if err == bookings.ErrNotCovered || err == bookings.ErrNeedsPayment {
+			skipErr := tx.Model(&entry).Update("status", StatusSkipped).Error
+			if skipErr != nil {
+				return skipErr
+			}
+
+			log.Infof("Skipped waitlist entry %d for class %d on %s: %s", entry.ID, classID, date.Format("2006-01-02"), err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		promotedAt := time.Now().UTC()
+		err = tx.Model(&entry).Updates(map[string]interface{}{
+			"status":      StatusPromoted,
+			"promoted_at": promotedAt,
+			"booking_id":  booking.ID,
+			// Notified by NotifyPromoted once committed, unless the member booked the session themselves
+			"notice_pending": created && notify.Enabled(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Promoted waitlist entry %d to booking %d for class %d on %s", entry.ID, booking.ID, classID, date.Format("2006-01-02"))
+	}
+}
+
+// NotifyPromoted notify members about the bookings they were promoted to from the waitlist, once
+// the promotions have been committed. Each entry is claimed before notifying its member, so that
+// nobody is notified twice. Returns how many were notified.
+func NotifyPromoted() (int, error) {
+	var promoted []Entry
+	err := db.Where("notice_pending = ?", true).Order("id ASC").Find(&promoted).Error
+	if err != nil {
+		return 0, err
+	}
+
+	notified := 0
+	for _, entry := range promoted {
+		claim := db.Model(&entry).Where("notice_pending = ?", true).Update("notice_pending", false)
+		if claim.Error != nil {
+			log.Errorf("Error claiming promotion notice of waitlist entry %d: %s", entry.ID, claim.Error)
+			continue
+		}
+		if claim.RowsAffected == 0 || entry.BookingID == nil {
+			continue
+		}
+
+		var booking bookings.Booking
+		err = db.First(&booking, *entry.BookingID).Error
+		if err != nil {
+			log.Errorf("Error fetching booking %d of waitlist entry %d: %s", *entry.BookingID, entry.ID, err)
+			continue
+		}
+
+		bookings.NotifyCreated(&booking)
+		notified++
+	}
+
+	return notified, nil
+}
@@ -0,0 +1,39 @@
+package waitlist
+
+import (
+	"testing"
+	"time"
+
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func TestGetEntryFromReq(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "waitlist_entries"  WHERE (class_id = 1) AND ("waitlist_entries"."id" = 3)`).WithReply([]map[string]interface{}{{
+		"id":           3,
+		"class_id":     1,
+		"member_id":    6,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":       StatusWaiting,
+	}})
+	w, r := makeRequest(nil, map[string]string{"id": "1", "entryID": "3"})
+
+	entry, err := db.getEntryFromReq(w, r)
+	if err != nil {
+		t.Fatal("Error getting waitlist entry by request vars")
+	}
+
+	if entry.ID != 3 || entry.ClassID != 1 || entry.MemberID != 6 || entry.Status != StatusWaiting ||
+		!entry.BookingDate.Equal(time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Retrieved waitlist entry didn't match expectations:", *entry)
+	}
+}
+
+func TestGetEntryFromReqOtherClass(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "2", "entryID": "3"})
+
+	_, err := db.getEntryFromReq(w, r)
+	if err == nil {
+		t.Error("Expected entry of another class not to be found")
+	}
+}
@@ -0,0 +1,76 @@
+package waitlist
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Waitlist entry statuses
+const (
+	StatusWaiting  = "waiting"
+	StatusPromoted = "promoted"
+	// Member had no valid membership or pass when their turn came
+	StatusSkipped = "skipped"
+)
+
+// Entry representation of waitlist.waitlist_entries
+type Entry struct {
+	ID          uint64     `gorm:"primary_key" json:"id"`
+	ClassID     uint64     `json:"class_id"`
+	MemberID    uint64     `json:"member_id"`
+	BookingDate time.Time  `gorm:"type:date" json:"booking_date"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PromotedAt  *time.Time `json:"promoted_at"`
+	BookingID   *uint64    `json:"booking_id"`
+	// Member is yet to be notified about the booking they were promoted to
+	NoticePending bool `gorm:"index" json:"-"`
+	Position      int  `gorm:"-" json:"position,omitempty"`
+}
+
+// TableName to keep waitlist entries apart from other entries
+func (Entry) TableName() string {
+	return "waitlist_entries"
+}
+
+// MarshalJSON to date correctly
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	type Alias Entry
+	return json.Marshal(&struct {
+		BookingDate string `json:"booking_date"`
+		*Alias
+	}{
+		BookingDate: e.BookingDate.Format("2006-01-02"),
+		Alias:       (*Alias)(e),
+	})
+}
+
+// UnmarshalJSON to date correctly and only accept the fields a member can choose
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		MemberID    uint64 `json:"member_id"`
+		BookingDate string `json:"booking_date"`
+	}{}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.MemberID == 0 {
+		return errors.New("Missing member_id in payload")
+	}
+	e.MemberID = aux.MemberID
+
+	if len(aux.BookingDate) < 10 {
+		return errors.New("Invalid booking_date in payload")
+	}
+
+	e.BookingDate, err = time.Parse("2006-01-02", aux.BookingDate[0:10])
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
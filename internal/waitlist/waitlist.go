@@ -0,0 +1,122 @@
+package waitlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getWaitlist(w http.ResponseWriter, r *http.Request) {
+	classID, err := getClassIDFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	query := db.Where("class_id = ? AND status = ?", classID, StatusWaiting)
+	if date := r.URL.Query().Get("date"); date != "" {
+		bookingDate, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			log.Warnf("Requested waitlist date (%s) is invalid: %s", date, err)
+			helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid date")
+			return
+		}
+		query = query.Where("booking_date = ?", bookingDate)
+	}
+
+	var entries []Entry
+	err = query.Order("booking_date ASC").Order("id ASC").Find(&entries).Error
+	if err != nil {
+		log.Error("Error fetching waitlist from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	// Entries are in queue order within each date
+	positions := map[string]int{}
+	for i := range entries {
+		date := entries[i].BookingDate.Format("2006-01-02")
+		positions[date]++
+		entries[i].Position = positions[date]
+	}
+
+	json.NewEncoder(w).Encode(&entries)
+}
+
+func joinWaitlist(w http.ResponseWriter, r *http.Request) {
+	classID, err := getClassIDFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	var entry Entry
+	err = json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		log.Warn("Error parsing JSON when joining waitlist: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for waitlist entry")
+		return
+	}
+	entry.ClassID = classID
+
+	err = bookings.Validate(bookings.Booking{MemberID: entry.MemberID, BookingDate: entry.BookingDate, ClassID: classID})
+	if err != nil {
+		helpers.ResponseJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = db.joinWaitlist(&entry)
+	if err == ErrSpotsLeft || err == ErrAlreadyWaiting {
+		helpers.ResponseJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error inserting waitlist entry to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&entry)
+}
+
+func getEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := db.getEntryFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&entry)
+}
+
+func leaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	entry, err := db.getEntryFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = db.Delete(&entry).Error
+	if err != nil {
+		log.Error("Error deleting waitlist entry from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Waitlist entry removed")
+}
+
+// Routes set routes for /classes/{id}/waitlist and promote waiting entries when spots free up
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Entry{})
+	bookings.OnSpotFreed(promoteFreed)
+
+	router.HandleFunc("", getWaitlist).Methods("GET")
+	router.HandleFunc("", joinWaitlist).Methods("POST")
+	router.HandleFunc("/{entryID}", getEntry).Methods("GET")
+	router.HandleFunc("/{entryID}", leaveWaitlist).Methods("DELETE")
+}
@@ -0,0 +1,412 @@
+package waitlist
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+	"github.com/teeaa/studio/internal/bookings"
+	"github.com/teeaa/studio/internal/classes"
+	"github.com/teeaa/studio/internal/members"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+	classes.SetupExternally(classes.Database(db))
+	bookings.SetupExternally(bookings.Database(db))
+	members.SetupExternally(members.Database(db))
+}
+
+// Mock get class by id response for the class owning the waitlist
+func setClassMatch() {
+	commonReply := []map[string]interface{}{{
+		"id":         1,
+		"name":       "Class #1",
+		"start_date": time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply(commonReply)
+}
+
+// Mock get member by id response for the waiting member
+func setMemberMatch() {
+	commonReply := []map[string]interface{}{{
+		"id":   6,
+		"name": "Patient Tester",
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "members"  WHERE ("members"."id" = 6) ORDER BY "members"."id" ASC LIMIT 1`).WithReply(commonReply)
+}
+
+// Mock member holding membership covering their bookings
+func setMembershipMatch(memberID int) {
+	commonReply := []map[string]interface{}{{
+		"id":         2,
+		"member_id":  memberID,
+		"start_date": time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		"status":     "active",
+	}}
+	mocket.Catcher.NewMock().WithQuery(fmt.Sprintf(`SELECT * FROM "memberships"  WHERE (member_id = %d `, memberID)).WithReply(commonReply)
+}
+
+// Mock the amount of existing bookings counted against class capacity
+func setBookedCount(count int) {
+	commonReply := []map[string]interface{}{{"count(*)": count}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply(commonReply)
+}
+
+func TestJoinWaitlist(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(20)
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "waitlist_entries"`).WithReply([]map[string]interface{}{{"count(*)": 0}})
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "waitlist_entries"`).WithID(3)
+
+	w, r := makeRequest(map[string]interface{}{"member_id": 6, "booking_date": "2019-08-11"}, map[string]string{"id": "1"})
+	joinWaitlist(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201 OK, got %d instead", w.Code)
+	}
+
+	var entry map[string]interface{}
+	err = json.Unmarshal(body, &entry)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+
+	if entry["id"] != float64(3) || entry["class_id"] != float64(1) || entry["status"] != StatusWaiting || entry["booking_date"] != "2019-08-11" {
+		t.Error("Received waitlist entry didn't match expectations:", entry)
+	}
+}
+
+func TestJoinWaitlistSpotsLeft(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(19)
+
+	w, r := makeRequest(map[string]interface{}{"member_id": 6, "booking_date": "2019-08-11"}, map[string]string{"id": "1"})
+	joinWaitlist(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func TestJoinWaitlistOutsideClass(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	setMemberMatch()
+	setBookedCount(20)
+
+	w, r := makeRequest(map[string]interface{}{"member_id": 6, "booking_date": "2019-05-11"}, map[string]string{"id": "1"})
+	joinWaitlist(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status 400, got %d instead", w.Code)
+	}
+}
+
+func TestGetWaitlist(t *testing.T) {
+	commonReply := []map[string]interface{}{{
+		"id":           3,
+		"class_id":     1,
+		"member_id":    6,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":       StatusWaiting,
+	}, {
+		"id":           5,
+		"class_id":     1,
+		"member_id":    8,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":       StatusWaiting,
+	}}
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "waitlist_entries"  WHERE (class_id = 1 AND status = waiting) AND (booking_date = 2019-08-11`).WithReply(commonReply)
+
+	w, r := makeRequest(nil, map[string]string{"id": "1"})
+	r.URL.RawQuery = "date=2019-08-11"
+	getWaitlist(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var entries []map[string]interface{}
+	err = json.Unmarshal(body, &entries)
+	if err != nil {
+		t.Error("Failed to serialise response to json:", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected waitlist length to be 2, was %d instead", len(entries))
+	}
+	if entries[0]["position"] != float64(1) || entries[1]["position"] != float64(2) {
+		t.Error("Waitlist positions didn't follow queue order:", entries)
+	}
+}
+
+func TestPromoteNext(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	entryReply := []map[string]interface{}{{
+		"id":           3,
+		"class_id":     1,
+		"member_id":    6,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":       StatusWaiting,
+	}}
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply([]map[string]interface{}{{"count(*)": 19}}).OneTime()
+	setBookedCount(20)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "waitlist_entries"`).WithReply(entryReply).OneTime()
+	setMembershipMatch(6)
+
+	var bookedMember interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "bookings"`).WithID(7).WithCallback(func(query string, args []driver.NamedValue) {
+		bookedMember = args[0].Value
+	})
+	var promotion string
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "waitlist_entries"`).WithCallback(func(query string, args []driver.NamedValue) {
+		promotion = query
+	})
+
+	err := promoteNext(db.DB, 1, time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error promoting waitlist:", err)
+	}
+
+	if bookedMember != int64(6) {
+		t.Errorf("Expected member of first waiting entry to be booked, got %v instead", bookedMember)
+	}
+	if !strings.Contains(promotion, `"status"`) || !strings.Contains(promotion, `"booking_id"`) {
+		t.Error("Promotion wasn't recorded on the waitlist entry:", promotion)
+	}
+}
+
+func TestPromoteNextSkipsNotCovered(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply([]map[string]interface{}{{"count(*)": 19}}).OneTime()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply([]map[string]interface{}{{"count(*)": 19}}).OneTime()
+	setBookedCount(20)
+	for _, memberID := range []int{6, 7} {
+		mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "waitlist_entries"`).WithReply([]map[string]interface{}{{
+			"id":           memberID - 3,
+			"class_id":     1,
+			"member_id":    memberID,
+			"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+			"status":       StatusWaiting,
+		}}).OneTime()
+	}
+	setMembershipMatch(7)
+
+	var bookedMembers []interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "bookings"`).WithID(7).WithCallback(func(query string, args []driver.NamedValue) {
+		bookedMembers = append(bookedMembers, args[0].Value)
+	})
+	var updates []interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "waitlist_entries"`).WithCallback(func(query string, args []driver.NamedValue) {
+		updates = append(updates, args[0].Value)
+	})
+
+	err := promoteNext(db.DB, 1, time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error promoting waitlist:", err)
+	}
+
+	if len(bookedMembers) != 1 || bookedMembers[0] != int64(7) {
+		t.Error("Expected only the covered member to be booked, booked:", bookedMembers)
+	}
+	if len(updates) != 2 || updates[0] != StatusSkipped {
+		t.Error("Expected the first entry to be skipped, updates:", updates)
+	}
+}
+
+func TestPromoteNextSkipsUnpaid(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "classes"  WHERE ("classes"."id" = 1) ORDER BY "classes"."id" ASC LIMIT 1`).WithReply([]map[string]interface{}{{
+		"id":         1,
+		"name":       "Class #1",
+		"start_date": time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 31, 0, 0, 0, 0, time.UTC),
+		"capacity":   20,
+		"price":      1500,
+	}})
+	setBookedCount(19)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "waitlist_entries"`).WithReply([]map[string]interface{}{{
+		"id":           3,
+		"class_id":     1,
+		"member_id":    6,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":       StatusWaiting,
+	}}).OneTime()
+
+	var inserted []string
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO`).WithCallback(func(query string, args []driver.NamedValue) {
+		inserted = append(inserted, query)
+	})
+	var updates []interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "waitlist_entries"`).WithCallback(func(query string, args []driver.NamedValue) {
+		updates = append(updates, args[0].Value)
+	})
+
+	err := promoteNext(db.DB, 1, time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error promoting waitlist:", err)
+	}
+
+	if len(inserted) != 0 {
+		t.Error("Expected nothing to be invoiced or booked for a member who'd have to pay online, inserted:", inserted)
+	}
+	if len(updates) != 1 || updates[0] != StatusSkipped {
+		t.Error("Expected the entry to be skipped, updates:", updates)
+	}
+}
+
+func TestPromoteNextAlreadyBooked(t *testing.T) {
+	mocket.Catcher.Reset()
+	setClassMatch()
+	mocket.Catcher.NewMock().WithQuery(`SELECT count(*) FROM "bookings"`).WithReply([]map[string]interface{}{{"count(*)": 19}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "waitlist_entries"`).WithReply([]map[string]interface{}{{
+		"id":           3,
+		"class_id":     1,
+		"member_id":    6,
+		"booking_date": time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":       StatusWaiting,
+	}}).OneTime()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"  WHERE (member_id = 6 AND class_id = 1`).WithReply([]map[string]interface{}{{
+		"id":        9,
+		"member_id": 6,
+		"class_id":  1,
+	}})
+
+	inserted := false
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "bookings"`).WithCallback(func(query string, args []driver.NamedValue) {
+		inserted = true
+	})
+	var promotedTo interface{}
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "waitlist_entries"`).WithCallback(func(query string, args []driver.NamedValue) {
+		for _, arg := range args {
+			if arg.Value == int64(9) {
+				promotedTo = arg.Value
+			}
+		}
+	})
+
+	err := promoteNext(db.DB, 1, time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Error promoting waitlist:", err)
+	}
+
+	if inserted {
+		t.Error("Expected member who already booked the session not to be booked twice")
+	}
+	if promotedTo != int64(9) {
+		t.Error("Expected waitlist entry to point to the existing booking")
+	}
+}
+
+func TestNotifyPromoted(t *testing.T) {
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "waitlist_entries"  WHERE (notice_pending = true)`).WithReply([]map[string]interface{}{{
+		"id":             3,
+		"class_id":       1,
+		"member_id":      6,
+		"booking_date":   time.Date(2019, 8, 11, 0, 0, 0, 0, time.UTC),
+		"status":         StatusPromoted,
+		"booking_id":     7,
+		"notice_pending": true,
+	}})
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "bookings"`).WithReply([]map[string]interface{}{{"id": 7, "member_id": 6, "class_id": 1}})
+	var claim string
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "waitlist_entries"`).WithCallback(func(query string, args []driver.NamedValue) {
+		claim = query
+	}).WithRowsNum(1)
+
+	notified, err := NotifyPromoted()
+	if err != nil {
+		t.Error("Error notifying promotions:", err)
+	}
+
+	if notified != 1 {
+		t.Errorf("Expected the promoted member to be notified, notified %d", notified)
+	}
+	if !strings.Contains(claim, "notice_pending = ?") {
+		t.Error("Expected the entry to be claimed before notifying, got:", claim)
+	}
+}
+
+func TestLeaveWaitlist(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "1", "entryID": "3"})
+	mocket.Catcher.Reset().NewMock().WithQuery(`SELECT * FROM "waitlist_entries"  WHERE (class_id = 1) AND ("waitlist_entries"."id" = 3)`).WithReply([]map[string]interface{}{{
+		"id":       3,
+		"class_id": 1,
+	}})
+
+	leaveWaitlist(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status 200 OK, got %d instead", w.Code)
+	}
+
+	var responseBody map[string]string
+	err = json.Unmarshal(body, &responseBody)
+	if err != nil {
+		t.Error("Error unmarshalling body:", err)
+	}
+
+	if responseBody["message"] != "Waitlist entry removed" {
+		t.Error("Response body didn't match expectations:", responseBody)
+	}
+}
+
+func TestLeaveWaitlistNonExisting(t *testing.T) {
+	w, r := makeRequest(nil, map[string]string{"id": "1", "entryID": "345"})
+
+	leaveWaitlist(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected HTTP status 404, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/classes/1/waitlist", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}
@@ -0,0 +1,289 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+// Database wrapper
+type Database struct {
+	*gorm.DB
+}
+
+var db Database
+
+// SetupExternally to set db from imports
+func SetupExternally(database Database) {
+	db = database
+}
+
+// Attempts made to deliver an event before giving up, and the delay before the first retry
+// which doubles on each attempt
+const maxAttempts = 8
+
+var retryDelay = time.Minute
+
+// Client deliveries are made with. Subscribers must respond in time for a delivery to succeed.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Emit event with data to the active subscriptions of the event. A delivery is recorded for
+// each of them and attempted in the background, so that the handler emitting the event isn't
+// held up. Failures are only logged, as the change the event is about has already been saved.
+// Nothing is emitted until the routes have been set up.
+func Emit(event string, data interface{}) {
+	if db.DB == nil {
+		return
+	}
+
+	// Schedule a retry ahead, so that the delivery is picked up again if the
+	// server stops before the first attempt completes
+	deliveries, err := record(db.DB, event, data, time.Now().UTC().Add(retryDelay))
+	if err != nil {
+		log.Errorf("Error recording %s webhook deliveries: %s", event, err)
+	}
+
+	for _, delivery := range deliveries {
+		go func(delivery Delivery) {
+			err := db.attempt(&delivery, time.Now().UTC(), true)
+			if err != nil {
+				log.Errorf("Error saving webhook delivery %d to db: %s", delivery.ID, err)
+			}
+		}(delivery)
+	}
+}
+
+// EmitTx emit event with data inside transaction tx, for changes made where there's no handler
+// to emit from once they're saved. The deliveries are recorded with the rest of the transaction
+// and attempted by DeliverDue once due, so that nothing is sent about changes rolled back.
+func EmitTx(tx *gorm.DB, event string, data interface{}) error {
+	if db.DB == nil {
+		return nil
+	}
+
+	_, err := record(tx, event, data, time.Now().UTC())
+	return err
+}
+
+// Record a pending delivery of event with data to each active subscription of the event through
+// tx, to be attempted at next. Returns the deliveries recorded before any error.
+func record(tx *gorm.DB, event string, data interface{}, next time.Time) ([]Delivery, error) {
+	encoded, err := json.Marshal(data)
+	if err == nil {
+		encoded, err = json.Marshal(Body{Event: event, OccurredAt: time.Now().UTC(), Data: encoded})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []Subscription
+	err = tx.Where("active = ?", true).Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []Delivery{}
+	for _, subscription := range subscriptions {
+		if !subscription.Events.Has(event) {
+			continue
+		}
+
+		at := next
+		delivery := Delivery{
+			SubscriptionID: subscription.ID,
+			Event:          event,
+			Payload:        string(encoded),
+			Status:         StatusPending,
+			NextAttemptAt:  &at,
+		}
+		err = tx.Create(&delivery).Error
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// DeliverDue attempt pending deliveries whose retry is due at now, returning how many succeeded
+func DeliverDue(now time.Time) (int, error) {
+	var due []Delivery
+	err := db.Where("status = ? AND next_attempt_at <= ?", StatusPending, now).Order("next_attempt_at ASC").Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range due {
+		err = db.attempt(&due[i], now, true)
+		if err != nil {
+			return delivered, err
+		}
+		if due[i].Status == StatusSucceeded {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// Sign body sent at timestamp with secret, as the hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+func sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Generate a random secret for signing deliveries
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Post delivery to the subscription at now, telling the status code responded with
+func post(subscription *Subscription, delivery *Delivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequest("POST", subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "DanceStudio-Webhooks")
+	request.Header.Set("X-Studio-Event", delivery.Event)
+	request.Header.Set("X-Studio-Delivery", strconv.FormatUint(delivery.ID, 10))
+	request.Header.Set("X-Studio-Timestamp", timestamp)
+	request.Header.Set("X-Studio-Signature", "sha256="+sign(subscription.Secret, timestamp, body))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("Subscriber responded with %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// Attempt delivery at now and save its outcome. A failed attempt is retried later with a
+// doubled delay when retry is set and attempts are left, otherwise the delivery fails.
+// Deliveries to removed or deactivated subscriptions fail without being sent.
+func (db *Database) attempt(delivery *Delivery, now time.Time, retry bool) error {
+	var subscription Subscription
+	err := db.First(&subscription, delivery.SubscriptionID).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	delivery.ResponseStatus = 0
+	switch {
+	case err != nil:
+		err = fmt.Errorf("Subscription %d has been removed", delivery.SubscriptionID)
+		retry = false
+	case !subscription.Active:
+		err = fmt.Errorf("Subscription %d is not active", delivery.SubscriptionID)
+		retry = false
+	default:
+		delivery.Attempts++
+		delivery.LastAttemptAt = &now
+		delivery.ResponseStatus, err = post(&subscription, delivery, now)
+	}
+
+	delivery.NextAttemptAt = nil
+	if err == nil {
+		delivery.Status = StatusSucceeded
+		delivery.Error = ""
+		delivery.DeliveredAt = &now
+	} else if retry && delivery.Attempts < maxAttempts {
+		next := now.Add(retryDelay * time.Duration(1<<uint(delivery.Attempts-1)))
+		delivery.Status = StatusPending
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = &next
+		log.Warnf("Webhook delivery %d of %s failed, retrying at %s: %s", delivery.ID, delivery.Event, next.Format(time.RFC3339), err)
+	} else {
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+		log.Errorf("Webhook delivery %d of %s failed after %d attempts: %s", delivery.ID, delivery.Event, delivery.Attempts, err)
+	}
+
+	return db.Save(delivery).Error
+}
+
+// Get subscription from database by id in request and handle error situations
+func (db *Database) getSubscriptionFromReq(w http.ResponseWriter, r *http.Request) (*Subscription, error) {
+	var subscription Subscription
+	vars := mux.Vars(r)
+	subscriptionID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		log.Warnf("Requested webhook subscription id (%s) is not an integer: %s", vars["id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid webhook subscription ID")
+		return nil, err
+	}
+
+	err = db.First(&subscription, subscriptionID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested webhook subscription by id %d does not exist", subscriptionID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Webhook subscription does not exist")
+		} else {
+			log.Error("Error fetching webhook subscription from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Get delivery of the subscription in request from database by id in request and handle error situations
+func (db *Database) getDeliveryFromReq(w http.ResponseWriter, r *http.Request) (*Delivery, error) {
+	subscription, err := db.getSubscriptionFromReq(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivery Delivery
+	vars := mux.Vars(r)
+	deliveryID, err := strconv.Atoi(vars["delivery_id"])
+	if err != nil {
+		log.Warnf("Requested webhook delivery id (%s) is not an integer: %s", vars["delivery_id"], err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid webhook delivery ID")
+		return nil, err
+	}
+
+	err = db.Where("subscription_id = ?", subscription.ID).First(&delivery, deliveryID).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			log.Warnf("Requested webhook delivery by id %d does not exist", deliveryID)
+			helpers.ResponseJSON(w, http.StatusNotFound, "Webhook delivery does not exist")
+		} else {
+			log.Error("Error fetching webhook delivery from db: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,154 @@
+package webhooks
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Events subscriptions can be made to
+const (
+	EventBookingCreated   = "booking.created"
+	EventBookingCancelled = "booking.cancelled"
+	EventClassCreated     = "class.created"
+	EventClassUpdated     = "class.updated"
+	EventClassDeleted     = "class.deleted"
+)
+
+var events = []string{EventBookingCreated, EventBookingCancelled, EventClassCreated, EventClassUpdated, EventClassDeleted}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Events list of event names, stored as comma separated names
+type Events []string
+
+// Has tells if event is in the list
+func (e Events) Has(event string) bool {
+	for _, name := range e {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Value to store events as comma separated names
+func (e Events) Value() (driver.Value, error) {
+	return strings.Join(e, ","), nil
+}
+
+// Scan events from comma separated names
+func (e *Events) Scan(value interface{}) error {
+	var names string
+	switch v := value.(type) {
+	case nil:
+		*e = Events{}
+		return nil
+	case []byte:
+		names = string(v)
+	case string:
+		names = v
+	default:
+		return fmt.Errorf("Unable to scan events from %T", value)
+	}
+
+	*e = Events{}
+	if names != "" {
+		*e = strings.Split(names, ",")
+	}
+	return nil
+}
+
+// Subscription of a URL to events. Deliveries to it are signed with Secret, which is
+// generated when not given.
+type Subscription struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	URL       string    `gorm:"type:varchar(2048)" json:"url"`
+	Events    Events    `gorm:"type:varchar(255)" json:"events"`
+	Secret    string    `json:"secret"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName to keep subscriptions next to their deliveries
+func (Subscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Delivery of an event to a subscription, with the outcome of its latest attempt. Pending
+// deliveries are attempted again at NextAttemptAt, and failed ones have run out of attempts.
+type Delivery struct {
+	ID             uint64     `gorm:"primary_key" json:"id"`
+	SubscriptionID uint64     `gorm:"index" json:"subscription_id"`
+	Event          string     `json:"event"`
+	Payload        string     `gorm:"type:text" json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       uint       `json:"attempts"`
+	ResponseStatus int        `json:"response_status"`
+	Error          string     `gorm:"type:text" json:"error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+// TableName to keep deliveries next to subscriptions
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Body of a delivery, with the event data as it was when the event happened
+type Body struct {
+	Event      string          `json:"event"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// UnmarshalJSON to validate subscription and strip ID and read-only fields from requests
+func (s *Subscription) UnmarshalJSON(data []byte) error {
+	type Alias Subscription
+	aux := &struct {
+		ID        uint64    `gorm:"-" sql:"-" json:"id"`
+		CreatedAt time.Time `gorm:"-" sql:"-" json:"created_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(s),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := url.Parse(s.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("Invalid url in payload, expected an http or https URL")
+	}
+
+	if len(s.Events) == 0 {
+		return errors.New("Invalid events in payload, expected at least one of " + strings.Join(events, ", "))
+	}
+	seen := map[string]bool{}
+	subscribed := Events{}
+	for _, event := range s.Events {
+		if !Events(events).Has(event) {
+			return fmt.Errorf("Invalid event %s in payload, expected one of %s", event, strings.Join(events, ", "))
+		}
+		if !seen[event] {
+			seen[event] = true
+			subscribed = append(subscribed, event)
+		}
+	}
+	s.Events = subscribed
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/teeaa/studio/internal/helpers"
+)
+
+func getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	var subscriptions []Subscription
+	err := db.Order("id ASC").Find(&subscriptions).Error
+	if err != nil {
+		log.Error("Error fetching webhook subscriptions from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&subscriptions)
+}
+
+// Save subscription, generating its secret when it has none
+func saveSubscription(w http.ResponseWriter, subscription *Subscription) error {
+	if subscription.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			log.Error("Error generating webhook secret: ", err)
+			helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+			return err
+		}
+		subscription.Secret = secret
+	}
+
+	err := db.Save(subscription).Error
+	if err != nil {
+		log.Error("Error saving webhook subscription to db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+	}
+	return err
+}
+
+func addSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription := Subscription{Active: true}
+	err := json.NewDecoder(r.Body).Decode(&subscription)
+	if err != nil {
+		log.Warn("Error parsing JSON when creating new webhook subscription: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for webhook subscription")
+		return
+	}
+
+	err = saveSubscription(w, &subscription)
+	if err != nil {
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&subscription)
+}
+
+func getSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := db.getSubscriptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&subscription)
+}
+
+func updateSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := db.getSubscriptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&subscription)
+	if err != nil {
+		log.Warn("Error parsing JSON when updating webhook subscription: ", err)
+		helpers.ResponseJSON(w, http.StatusBadRequest, "Invalid request body for webhook subscription")
+		return
+	}
+
+	err = saveSubscription(w, subscription)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&subscription)
+}
+
+// Deliveries of a removed subscription are kept in its delivery log
+func deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := db.getSubscriptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	err = db.Delete(&subscription).Error
+	if err != nil {
+		log.Error("Error deleting webhook subscription from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	helpers.ResponseJSON(w, 200, "Webhook subscription removed")
+}
+
+func getDeliveries(w http.ResponseWriter, r *http.Request) {
+	subscription, err := db.getSubscriptionFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	query := db.Where("subscription_id = ?", subscription.ID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []Delivery
+	err = query.Order("id DESC").Find(&deliveries).Error
+	if err != nil {
+		log.Error("Error fetching webhook deliveries from db: ", err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&deliveries)
+}
+
+func getDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := db.getDeliveryFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	json.NewEncoder(w).Encode(&delivery)
+}
+
+// Attempt a failed delivery once more with its original payload, responding with the outcome
+func replayDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := db.getDeliveryFromReq(w, r)
+	if err != nil {
+		return
+	}
+
+	if delivery.Status != StatusFailed {
+		helpers.ResponseJSON(w, http.StatusConflict, "Only failed deliveries can be replayed")
+		return
+	}
+
+	err = db.attempt(delivery, time.Now().UTC(), false)
+	if err != nil {
+		log.Errorf("Error saving webhook delivery %d to db: %s", delivery.ID, err)
+		helpers.ResponseJSON(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	json.NewEncoder(w).Encode(&delivery)
+}
+
+// Routes set routes for /webhooks
+func Routes(gormDB *gorm.DB, router *mux.Router) {
+	db = Database{gormDB}
+	db.AutoMigrate(&Subscription{}, &Delivery{})
+
+	router.HandleFunc("", getSubscriptions).Methods("GET")
+	router.HandleFunc("", addSubscription).Methods("POST")
+	router.HandleFunc("/{id}", getSubscription).Methods("GET")
+	router.HandleFunc("/{id}", updateSubscription).Methods("PUT")
+	router.HandleFunc("/{id}", deleteSubscription).Methods("DELETE")
+	router.HandleFunc("/{id}/deliveries", getDeliveries).Methods("GET")
+	router.HandleFunc("/{id}/deliveries/{delivery_id}", getDelivery).Methods("GET")
+	router.HandleFunc("/{id}/deliveries/{delivery_id}/replay", replayDelivery).Methods("POST")
+}
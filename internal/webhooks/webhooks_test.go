@@ -0,0 +1,227 @@
+package webhooks
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	mocket "github.com/selvatico/go-mocket"
+)
+
+func setup() {
+	mocket.Catcher.Register()
+	mocket.Catcher.Logging = true
+	gormDB, _ := gorm.Open(mocket.DriverName, "")
+	db = Database{gormDB}
+}
+
+// Mock active subscription 2 of url to booking.created signed with secret "s3cret"
+func setSubscriptionMatch(url string) {
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "webhook_subscriptions"`).WithReply([]map[string]interface{}{{
+		"id":     2,
+		"url":    url,
+		"events": "booking.created,booking.cancelled",
+		"secret": "s3cret",
+		"active": true,
+	}})
+}
+
+func TestAddSubscription(t *testing.T) {
+	setup()
+	mocket.Catcher.Reset()
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "webhook_subscriptions"`).WithID(2)
+
+	w, r := makeRequest(map[string]interface{}{"url": "https://example.com/hooks", "events": []string{"class.updated", "class.updated"}}, nil)
+	addSubscription(w, r)
+
+	body, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Error("Error reading body:", err)
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected HTTP status 201, got %d instead", w.Code)
+	}
+
+	var subscription Subscription
+	err = json.Unmarshal(body, &subscription)
+	if err != nil {
+		t.Error("Failed to unmarshalling response to json:", err)
+	}
+	if !subscription.Active || len(subscription.Events) != 1 || len(subscription.Secret) != 64 {
+		t.Error("Received subscription didn't match expectations:", subscription)
+	}
+}
+
+func TestAddSubscriptionInvalid(t *testing.T) {
+	mocket.Catcher.Reset()
+
+	for _, payload := range []map[string]interface{}{
+		{"url": "ftp://example.com/hooks", "events": []string{"class.updated"}},
+		{"url": "https://example.com/hooks", "events": []string{}},
+		{"url": "https://example.com/hooks", "events": []string{"member.created"}},
+	} {
+		w, r := makeRequest(payload, nil)
+		addSubscription(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected HTTP status 400 for %v, got %d instead", payload, w.Code)
+		}
+	}
+}
+
+func TestEmit(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	mocket.Catcher.Reset()
+	setSubscriptionMatch(server.URL)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "webhook_deliveries"`).WithID(3)
+	statuses := make(chan interface{}, 1)
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "webhook_deliveries"`).WithRowsNum(1).WithCallback(func(query string, args []driver.NamedValue) {
+		statuses <- args[3].Value
+	})
+
+	Emit(EventBookingCreated, map[string]interface{}{"id": 7})
+	Emit(EventClassDeleted, map[string]interface{}{"id": 1})
+
+	var request *http.Request
+	var body []byte
+	select {
+	case request = <-requests:
+		body = <-bodies
+	case <-time.After(time.Second):
+		t.Fatal("Subscriber didn't receive the delivery")
+	}
+
+	if request.Header.Get("X-Studio-Event") != EventBookingCreated || request.Header.Get("X-Studio-Delivery") != "3" {
+		t.Error("Delivery headers didn't match expectations:", request.Header)
+	}
+	expected := "sha256=" + sign("s3cret", request.Header.Get("X-Studio-Timestamp"), body)
+	if request.Header.Get("X-Studio-Signature") != expected {
+		t.Errorf("Expected signature %s, got %s", expected, request.Header.Get("X-Studio-Signature"))
+	}
+
+	var received Body
+	err := json.Unmarshal(body, &received)
+	if err != nil || received.Event != EventBookingCreated || string(received.Data) != `{"id":7}` {
+		t.Error("Delivery body didn't match expectations:", string(body))
+	}
+
+	select {
+	case status := <-statuses:
+		if status != StatusSucceeded {
+			t.Error("Expected delivery to be saved as succeeded, got:", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Delivery outcome wasn't saved")
+	}
+	select {
+	case <-requests:
+		t.Error("Expected only subscribed events to be delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEmitTx(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer server.Close()
+
+	mocket.Catcher.Reset()
+	setSubscriptionMatch(server.URL)
+	var payloads []interface{}
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "webhook_deliveries"`).WithID(3).WithCallback(func(query string, args []driver.NamedValue) {
+		// subscription_id, event, payload, ...
+		payloads = append(payloads, args[2].Value)
+	})
+
+	err := EmitTx(db.DB, EventBookingCancelled, map[string]interface{}{"id": 7})
+	if err != nil {
+		t.Error("Error emitting event:", err)
+	}
+
+	if len(payloads) != 1 || !strings.Contains(payloads[0].(string), `"data":{"id":7}`) {
+		t.Error("Expected a delivery to be recorded, got:", payloads)
+	}
+	select {
+	case <-requests:
+		t.Error("Expected the delivery to be left for DeliverDue")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAttemptRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	mocket.Catcher.Reset()
+	setSubscriptionMatch(server.URL)
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "webhook_deliveries"`).WithRowsNum(1)
+
+	now := time.Date(2019, 7, 15, 12, 0, 0, 0, time.UTC)
+	delivery := Delivery{ID: 3, SubscriptionID: 2, Event: EventBookingCreated, Payload: "{}", Status: StatusPending, Attempts: 2}
+	err := db.attempt(&delivery, now, true)
+	if err != nil {
+		t.Error("Error saving delivery:", err)
+	}
+
+	if delivery.Status != StatusPending || delivery.Attempts != 3 || delivery.ResponseStatus != http.StatusServiceUnavailable {
+		t.Error("Delivery didn't match expectations:", delivery)
+	}
+	if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(now.Add(4*time.Minute)) {
+		t.Error("Expected retry to back off for 4 minutes, got:", delivery.NextAttemptAt)
+	}
+
+	delivery.Attempts = maxAttempts - 1
+	err = db.attempt(&delivery, now, true)
+	if err != nil {
+		t.Error("Error saving delivery:", err)
+	}
+	if delivery.Status != StatusFailed || delivery.NextAttemptAt != nil {
+		t.Error("Expected delivery to fail after the last attempt, got:", delivery)
+	}
+}
+
+func TestReplayPendingDelivery(t *testing.T) {
+	mocket.Catcher.Reset()
+	setSubscriptionMatch("https://example.com/hooks")
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "webhook_deliveries"`).WithReply([]map[string]interface{}{{"id": 3, "subscription_id": 2, "status": StatusPending}})
+
+	w, r := makeRequest(nil, map[string]string{"id": "2", "delivery_id": "3"})
+	replayDelivery(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected HTTP status 409, got %d instead", w.Code)
+	}
+}
+
+func makeRequest(requestData map[string]interface{}, vars map[string]string) (*httptest.ResponseRecorder, *http.Request) {
+	requestBody, _ := json.Marshal(&requestData)
+
+	r := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(requestBody))
+	r.Header.Add("Content-Type", "application/json")
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	w.Header().Add("Content-Type", "application/json")
+
+	return w, r
+}